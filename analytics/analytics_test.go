@@ -0,0 +1,81 @@
+// analytics/analytics_test.go
+package analytics_test
+
+import (
+	"estiam/analytics"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLineFormats(t *testing.T) {
+	// 1. JSON records keep their route template, status and latency.
+	r, ok := analytics.ParseLine(`{"time":"2024-05-01T10:00:00Z","level":"INFO","msg":"request","method":"GET","uri":"/get/chat","route":"/get/{word}","status":200,"bytes":42,"latency_ms":1.5,"user":"alice"}`)
+	assert.True(t, ok)
+	assert.Equal(t, "/get/{word}", r.Route)
+	assert.Equal(t, 200, r.Status)
+	assert.Equal(t, 1500*time.Microsecond, r.Latency)
+	assert.Equal(t, "alice", r.User)
+
+	// 2. logfmt records, with quoted values.
+	r, ok = analytics.ParseLine(`time=2024-05-01T10:00:00.000Z level=WARN msg=request method=GET uri=/get/x%20y route=/get/{word} status=404 bytes=10 latency_ms=0.25 user="" request_id=abc user_agent="curl/8.0 (x)"`)
+	assert.True(t, ok)
+	assert.Equal(t, 404, r.Status)
+	word, ok := r.Word()
+	assert.True(t, ok)
+	assert.Equal(t, "x y", word)
+
+	// 3. Combined lines have no latency; the route is derived from the path.
+	r, ok = analytics.ParseLine(`10.0.0.1 - bob [01/May/2024:10:00:00 +0000] "GET /get/chat?x=1 HTTP/1.1" 200 12 "" "curl"`)
+	assert.True(t, ok)
+	assert.Equal(t, "/get/{word}", r.Route)
+	assert.Equal(t, "bob", r.User)
+	assert.False(t, r.HasLatency())
+
+	// 4. Legacy lines have no status.
+	r, ok = analytics.ParseLine(`2023/11/22 16:37:38 [1.0805ms] DELETE /remove/chat [::1]:57189`)
+	assert.True(t, ok)
+	assert.Equal(t, "/remove/{word}", r.Route)
+	assert.False(t, r.HasStatus())
+	_, ok = r.Word()
+	assert.False(t, ok)
+
+	// 5. Other records are skipped.
+	_, ok = analytics.ParseLine(`{"time":"2024-05-01T10:00:00Z","level":"WARN","msg":"access denied: subject=\"x\""}`)
+	assert.False(t, ok)
+}
+
+func TestAnalyzerReport(t *testing.T) {
+	// 1. Analyze a log mixing formats.
+	log := strings.Join([]string{
+		`2024/05/01 10:00:00 [1ms] GET /get/chat [::1]:1`,
+		`2024/05/01 10:10:00 [2ms] GET /get/chat [::1]:1`,
+		`2024/05/01 10:20:00 [3ms] GET /get/chien [::1]:1`,
+		`{"time":"2024-05-01T11:00:00Z","msg":"request","method":"GET","uri":"/get/licorne","route":"/get/{word}","status":404,"latency_ms":4}`,
+		`{"time":"2024-05-01T11:30:00Z","msg":"request","method":"GET","uri":"/list","route":"/list","status":500,"latency_ms":100}`,
+		`not a record`,
+	}, "\n")
+
+	a := analytics.NewAnalyzer(analytics.Options{Top: 5, Bucket: time.Hour})
+	assert.NoError(t, a.Read(strings.NewReader(log)))
+	report := a.Report()
+
+	// 2. Check words, misses, latencies and traffic.
+	assert.Equal(t, 5, report.Requests)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, []analytics.Count{{Word: "chat", Count: 2}, {Word: "chien", Count: 1}}, report.TopWords)
+	assert.Equal(t, []analytics.Count{{Word: "licorne", Count: 1}}, report.Misses)
+
+	assert.Len(t, report.Latency, 2)
+	assert.Equal(t, "/get/{word}", report.Latency[0].Route)
+	assert.Equal(t, 4, report.Latency[0].Requests)
+	assert.Equal(t, 2.0, report.Latency[0].P50MS)
+	assert.Equal(t, 4.0, report.Latency[0].P99MS)
+
+	assert.Len(t, report.Traffic, 2)
+	assert.Equal(t, 3, report.Traffic[0].Requests)
+	assert.Equal(t, 2, report.Traffic[1].Requests)
+	assert.Equal(t, 1, report.Traffic[1].Errors)
+}
@@ -0,0 +1,247 @@
+// analytics/parse.go
+package analytics
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is one request read from the access log. Fields missing from the log
+// format are left zero: the legacy format has no status, and the combined
+// format has no latency.
+type Record struct {
+	Time    time.Time
+	Method  string
+	URI     string
+	Route   string
+	Status  int
+	Bytes   int64
+	Latency time.Duration
+	User    string
+
+	// noLatency is set when the log format did not record the latency.
+	noLatency bool
+}
+
+// HasStatus reports whether the log format recorded the status code.
+func (r Record) HasStatus() bool {
+	return r.Status != 0
+}
+
+// HasLatency reports whether the log format recorded the latency.
+func (r Record) HasLatency() bool {
+	return !r.noLatency
+}
+
+// ParseLine parses a line written by middleware.Logger in the JSON, logfmt or
+// combined format, or by the legacy logger. Lines that are not request records,
+// such as access denials, are reported as not ok.
+func ParseLine(line string) (Record, bool) {
+	line = strings.TrimSpace(line)
+	switch {
+	case line == "":
+		return Record{}, false
+	case strings.HasPrefix(line, "{"):
+		return parseJSON(line)
+	case strings.HasPrefix(line, "time="):
+		return parseLogfmt(line)
+	}
+
+	if r, ok := parseCombined(line); ok {
+		return r, true
+	}
+	return parseLegacy(line)
+}
+
+// parseJSON parses a record of the JSON format.
+func parseJSON(line string) (Record, bool) {
+	var entry struct {
+		Time      time.Time `json:"time"`
+		Msg       string    `json:"msg"`
+		Method    string    `json:"method"`
+		URI       string    `json:"uri"`
+		Route     string    `json:"route"`
+		Status    int       `json:"status"`
+		Bytes     int64     `json:"bytes"`
+		LatencyMS float64   `json:"latency_ms"`
+		User      string    `json:"user"`
+	}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil || entry.Msg != "request" {
+		return Record{}, false
+	}
+
+	return withRoute(Record{
+		Time:    entry.Time,
+		Method:  entry.Method,
+		URI:     entry.URI,
+		Route:   entry.Route,
+		Status:  entry.Status,
+		Bytes:   entry.Bytes,
+		Latency: milliseconds(entry.LatencyMS),
+		User:    entry.User,
+	}), true
+}
+
+// parseLogfmt parses a record of the logfmt format.
+func parseLogfmt(line string) (Record, bool) {
+	fields := logfmtFields(line)
+	if fields["msg"] != "request" {
+		return Record{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, fields["time"])
+	if err != nil {
+		return Record{}, false
+	}
+	status, _ := strconv.Atoi(fields["status"])
+	bytes, _ := strconv.ParseInt(fields["bytes"], 10, 64)
+	latency, _ := strconv.ParseFloat(fields["latency_ms"], 64)
+
+	return withRoute(Record{
+		Time:    t,
+		Method:  fields["method"],
+		URI:     fields["uri"],
+		Route:   fields["route"],
+		Status:  status,
+		Bytes:   bytes,
+		Latency: milliseconds(latency),
+		User:    fields["user"],
+	}), true
+}
+
+// logfmtFields splits a logfmt line into its key=value pairs. Values may be
+// quoted with Go string syntax, as written by slog.
+func logfmtFields(line string) map[string]string {
+	fields := map[string]string{}
+	for line != "" {
+		line = strings.TrimLeft(line, " ")
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			break
+		}
+		key := line[:eq]
+		line = line[eq+1:]
+
+		var value string
+		if strings.HasPrefix(line, `"`) {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				break
+			}
+			value, _ = strconv.Unquote(quoted)
+			line = line[len(quoted):]
+		} else if sp := strings.IndexByte(line, ' '); sp >= 0 {
+			value, line = line[:sp], line[sp:]
+		} else {
+			value, line = line, ""
+		}
+		fields[key] = value
+	}
+	return fields
+}
+
+// combinedPattern matches the Apache combined log format.
+var combinedPattern = regexp.MustCompile(`^\S+ \S+ (\S+) \[([^\]]+)\] "(\S+) (\S+) [^"]*" (\d{3}) (\d+|-)`)
+
+// parseCombined parses a line of the Apache combined log format.
+func parseCombined(line string) (Record, bool) {
+	m := combinedPattern.FindStringSubmatch(line)
+	if m == nil {
+		return Record{}, false
+	}
+	t, err := time.Parse("02/Jan/2006:15:04:05 -0700", m[2])
+	if err != nil {
+		return Record{}, false
+	}
+
+	status, _ := strconv.Atoi(m[5])
+	bytes, _ := strconv.ParseInt(m[6], 10, 64)
+	user := m[1]
+	if user == "-" {
+		user = ""
+	}
+	return withRoute(Record{Time: t, Method: m[3], URI: m[4], Status: status, Bytes: bytes, User: user, noLatency: true}), true
+}
+
+// legacyPattern matches the lines of the original logger, e.g.
+// "2023/11/22 16:37:38 [1.0805ms] POST /add [::1]:57189".
+var legacyPattern = regexp.MustCompile(`^(\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}) \[([^\]]+)\] (\S+)(?: (\S*))?`)
+
+// parseLegacy parses a line of the original logger, which records neither
+// the status code nor the response size.
+func parseLegacy(line string) (Record, bool) {
+	m := legacyPattern.FindStringSubmatch(line)
+	if m == nil {
+		return Record{}, false
+	}
+	t, err := time.ParseInLocation("2006/01/02 15:04:05", m[1], time.Local)
+	if err != nil {
+		return Record{}, false
+	}
+	latency, err := time.ParseDuration(m[2])
+	if err != nil {
+		return Record{}, false
+	}
+	return withRoute(Record{Time: t, Method: m[3], URI: m[4], Latency: latency}), true
+}
+
+// wordRoutes maps the path prefixes of word routes to their templates, for
+// formats that do not record the route. Lookups are GET requests on them.
+var wordRoutes = []struct {
+	prefix, template string
+	lookup           bool
+}{
+	{"/get/", "/get/{word}", true},
+	{"/remove/", "/remove/{word}", false},
+	{"/v1/words/", "/v1/words/{word}", true},
+}
+
+// withRoute fills in the route template of r from its path when the log did not record it.
+func withRoute(r Record) Record {
+	if r.Route != "" {
+		return r
+	}
+
+	path := r.URI
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	r.Route = path
+	for _, route := range wordRoutes {
+		if strings.HasPrefix(path, route.prefix) && len(path) > len(route.prefix) {
+			r.Route = route.template
+		}
+	}
+	return r
+}
+
+// Word returns the word looked up by r, if r is a lookup.
+func (r Record) Word() (string, bool) {
+	if r.Method != "GET" {
+		return "", false
+	}
+
+	path := r.URI
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	for _, route := range wordRoutes {
+		if route.lookup && route.template == r.Route && strings.HasPrefix(path, route.prefix) {
+			word, err := url.PathUnescape(path[len(route.prefix):])
+			if err != nil || word == "" || strings.Contains(word, "/") {
+				return "", false
+			}
+			return word, true
+		}
+	}
+	return "", false
+}
+
+// milliseconds converts a number of milliseconds to a duration.
+func milliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
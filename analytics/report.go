@@ -0,0 +1,193 @@
+// analytics/report.go
+package analytics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options configures a Report.
+type Options struct {
+	// Top limits the lists of words and misses; it defaults to 10.
+	Top int
+	// Bucket is the width of the traffic buckets; it defaults to an hour.
+	Bucket time.Duration
+}
+
+// Count is a word and how many times it was requested.
+type Count struct {
+	Word  string `json:"word"`
+	Count int    `json:"count"`
+}
+
+// RouteLatency summarizes the latency of one route.
+type RouteLatency struct {
+	Route    string  `json:"route"`
+	Requests int     `json:"requests"`
+	P50MS    float64 `json:"p50_ms"`
+	P95MS    float64 `json:"p95_ms"`
+	P99MS    float64 `json:"p99_ms"`
+}
+
+// Traffic counts the requests and errors of one time bucket.
+type Traffic struct {
+	Start    time.Time `json:"start"`
+	Requests int       `json:"requests"`
+	Errors   int       `json:"errors"`
+}
+
+// Report summarizes an access log.
+type Report struct {
+	Requests int `json:"requests"`
+	// Skipped counts the lines that are not request records.
+	Skipped  int            `json:"skipped"`
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	TopWords []Count        `json:"top_words"`
+	Misses   []Count        `json:"misses"`
+	Latency  []RouteLatency `json:"latency"`
+	Traffic  []Traffic      `json:"traffic"`
+}
+
+// Analyzer accumulates the records of one or more access logs into a Report.
+type Analyzer struct {
+	options   Options
+	requests  int
+	skipped   int
+	from, to  time.Time
+	words     map[string]int
+	misses    map[string]int
+	latencies map[string][]time.Duration
+	traffic   map[time.Time]*Traffic
+}
+
+// NewAnalyzer creates an empty Analyzer.
+func NewAnalyzer(opts Options) *Analyzer {
+	if opts.Top <= 0 {
+		opts.Top = 10
+	}
+	if opts.Bucket <= 0 {
+		opts.Bucket = time.Hour
+	}
+	return &Analyzer{
+		options:   opts,
+		words:     map[string]int{},
+		misses:    map[string]int{},
+		latencies: map[string][]time.Duration{},
+		traffic:   map[time.Time]*Traffic{},
+	}
+}
+
+// Read adds every line of r to the analysis.
+func (a *Analyzer) Read(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if record, ok := ParseLine(scanner.Text()); ok {
+			a.Add(record)
+		} else if strings.TrimSpace(scanner.Text()) != "" {
+			a.skipped++
+		}
+	}
+	return scanner.Err()
+}
+
+// Add adds one record to the analysis.
+func (a *Analyzer) Add(r Record) {
+	a.requests++
+	if a.from.IsZero() || r.Time.Before(a.from) {
+		a.from = r.Time
+	}
+	if r.Time.After(a.to) {
+		a.to = r.Time
+	}
+
+	if word, ok := r.Word(); ok {
+		switch {
+		case r.Status == 404:
+			a.misses[word]++
+		case !r.HasStatus() || r.Status < 400:
+			a.words[word]++
+		}
+	}
+
+	if r.HasLatency() {
+		a.latencies[r.Route] = append(a.latencies[r.Route], r.Latency)
+	}
+
+	start := r.Time.Truncate(a.options.Bucket)
+	bucket, ok := a.traffic[start]
+	if !ok {
+		bucket = &Traffic{Start: start}
+		a.traffic[start] = bucket
+	}
+	bucket.Requests++
+	if r.Status >= 500 {
+		bucket.Errors++
+	}
+}
+
+// Report returns the analysis of the records added so far.
+func (a *Analyzer) Report() Report {
+	report := Report{
+		Requests: a.requests,
+		Skipped:  a.skipped,
+		From:     a.from,
+		To:       a.to,
+		TopWords: top(a.words, a.options.Top),
+		Misses:   top(a.misses, a.options.Top),
+		Latency:  []RouteLatency{},
+		Traffic:  []Traffic{},
+	}
+
+	for route, latencies := range a.latencies {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		report.Latency = append(report.Latency, RouteLatency{
+			Route:    route,
+			Requests: len(latencies),
+			P50MS:    percentile(latencies, 50),
+			P95MS:    percentile(latencies, 95),
+			P99MS:    percentile(latencies, 99),
+		})
+	}
+	sort.Slice(report.Latency, func(i, j int) bool { return report.Latency[i].Route < report.Latency[j].Route })
+
+	for _, bucket := range a.traffic {
+		report.Traffic = append(report.Traffic, *bucket)
+	}
+	sort.Slice(report.Traffic, func(i, j int) bool { return report.Traffic[i].Start.Before(report.Traffic[j].Start) })
+
+	return report
+}
+
+// top returns the n most frequent words of counts, most frequent first.
+func top(counts map[string]int, n int) []Count {
+	list := make([]Count, 0, len(counts))
+	for word, count := range counts {
+		list = append(list, Count{Word: word, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Word < list[j].Word
+	})
+	if len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// percentile returns the p-th percentile of sorted latencies in milliseconds,
+// using the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) float64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return float64(sorted[rank-1].Microseconds()) / 1000
+}
@@ -0,0 +1,186 @@
+// auth/apikey.go
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors returned by the API key store and manager.
+var (
+	// ErrKeyNotFound is returned when no key has the given ID.
+	ErrKeyNotFound = errors.New("api key not found")
+	// ErrKeyRevoked is returned when a revoked key is used or revoked again.
+	ErrKeyRevoked = errors.New("api key revoked")
+	// ErrInvalidKey is returned when a token is malformed or does not match its key.
+	ErrInvalidKey = errors.New("invalid api key")
+	// ErrInvalidKeyName is returned when a key is created without a name.
+	ErrInvalidKeyName = errors.New("api key name must not be empty")
+)
+
+// keyPrefix starts every API key token, making leaked keys easy to spot.
+const keyPrefix = "dk"
+
+// touchInterval limits how often the last-used timestamp of a key is written.
+const touchInterval = time.Minute
+
+// APIKey is a stored API key. Only a hash of its secret is kept.
+type APIKey struct {
+	ID         string    `bson:"_id" json:"id"`
+	Name       string    `bson:"name" json:"name"`
+	Hash       string    `bson:"hash" json:"-"`
+	Scopes     []Scope   `bson:"scopes" json:"scopes"`
+	CreatedAt  time.Time `bson:"created_at" json:"created_at"`
+	LastUsedAt time.Time `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+	RevokedAt  time.Time `bson:"revoked_at,omitempty" json:"revoked_at,omitempty"`
+}
+
+// Revoked reports whether the key has been revoked.
+func (k APIKey) Revoked() bool {
+	return !k.RevokedAt.IsZero()
+}
+
+// KeyStore persists API keys.
+type KeyStore interface {
+	Insert(ctx context.Context, key APIKey) error
+	Find(ctx context.Context, id string) (APIKey, error)
+	List(ctx context.Context) ([]APIKey, error)
+	Revoke(ctx context.Context, id string, at time.Time) error
+	Touch(ctx context.Context, id string, at time.Time) error
+}
+
+// KeyManager creates, lists, revokes and verifies API keys held in a KeyStore.
+type KeyManager struct {
+	store KeyStore
+	now   func() time.Time
+}
+
+// NewKeyManager creates a KeyManager backed by store.
+func NewKeyManager(store KeyStore) *KeyManager {
+	return &KeyManager{store: store, now: time.Now}
+}
+
+// Create stores a new key named name granting the given roles or scopes, and
+// returns it with its token. The token is only available here; the store keeps its hash.
+func (m *KeyManager) Create(ctx context.Context, name string, roles []string) (APIKey, string, error) {
+	if strings.TrimSpace(name) == "" {
+		return APIKey{}, "", ErrInvalidKeyName
+	}
+	scopes, err := ParseScopes(roles)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	if len(scopes) == 0 {
+		return APIKey{}, "", fmt.Errorf("%w: at least one role or scope is required", ErrInvalidScope)
+	}
+
+	id, err := randomString(6, hex.EncodeToString)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	secret, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return APIKey{}, "", err
+	}
+
+	key := APIKey{
+		ID:        id,
+		Name:      strings.TrimSpace(name),
+		Hash:      hashSecret(secret),
+		Scopes:    scopes,
+		CreatedAt: m.now().UTC().Truncate(time.Millisecond),
+	}
+	if err := m.store.Insert(ctx, key); err != nil {
+		return APIKey{}, "", err
+	}
+
+	return key, keyPrefix + "_" + id + "_" + secret, nil
+}
+
+// List returns every key, revoked ones included.
+func (m *KeyManager) List(ctx context.Context) ([]APIKey, error) {
+	return m.store.List(ctx)
+}
+
+// Revoke revokes the key with the given ID.
+func (m *KeyManager) Revoke(ctx context.Context, id string) error {
+	return m.store.Revoke(ctx, id, m.now().UTC())
+}
+
+// Verify checks a token against the store and returns its key.
+// Secrets are compared in constant time.
+func (m *KeyManager) Verify(ctx context.Context, token string) (APIKey, error) {
+	prefix, rest, ok := strings.Cut(token, "_")
+	if !ok || prefix != keyPrefix {
+		return APIKey{}, ErrInvalidKey
+	}
+	id, secret, ok := strings.Cut(rest, "_")
+	if !ok || id == "" || secret == "" {
+		return APIKey{}, ErrInvalidKey
+	}
+
+	key, err := m.store.Find(ctx, id)
+	if errors.Is(err, ErrKeyNotFound) {
+		return APIKey{}, ErrInvalidKey
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(key.Hash)) != 1 {
+		return APIKey{}, ErrInvalidKey
+	}
+	if key.Revoked() {
+		return APIKey{}, ErrKeyRevoked
+	}
+
+	now := m.now().UTC()
+	if now.Sub(key.LastUsedAt) >= touchInterval {
+		if err := m.store.Touch(ctx, key.ID, now); err == nil {
+			key.LastUsedAt = now
+		}
+	}
+	return key, nil
+}
+
+// Authenticate verifies the bearer token of r as an API key.
+func (m *KeyManager) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := BearerToken(r)
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+
+	key, err := m.Verify(r.Context(), token)
+	if errors.Is(err, ErrInvalidKey) || errors.Is(err, ErrKeyRevoked) {
+		return Identity{}, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+	if err != nil {
+		return Identity{}, fmt.Errorf("verifying api key: %w", err)
+	}
+	return Identity{Subject: key.Name, KeyID: key.ID, Scopes: key.Scopes}, nil
+}
+
+// hashSecret returns the hex SHA-256 of a key secret. Secrets are 256-bit random
+// values, so a fast hash is enough to keep them unrecoverable from the store.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomString encodes n random bytes with encode.
+func randomString(n int, encode func([]byte) string) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encode(b), nil
+}
@@ -0,0 +1,365 @@
+// auth_test.go
+package auth_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"estiam/auth"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyManagerLifecycle(t *testing.T) {
+	ctx := context.Background()
+	store := auth.NewMemoryKeyStore()
+	keys := auth.NewKeyManager(store)
+
+	// 1. Create a key and verify its token.
+	key, token, err := keys.Create(ctx, "ci", []string{"reader"})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, "dk_"+key.ID+"_"))
+	assert.NotContains(t, key.Hash, strings.TrimPrefix(token, "dk_"+key.ID+"_"), "The secret must not be stored")
+
+	verified, err := keys.Verify(ctx, token)
+	assert.NoError(t, err)
+	assert.Equal(t, "ci", verified.Name)
+	assert.False(t, verified.LastUsedAt.IsZero(), "Expected the last-used timestamp to be set")
+
+	// 2. Verify that a tampered secret is rejected.
+	_, err = keys.Verify(ctx, token+"x")
+	assert.ErrorIs(t, err, auth.ErrInvalidKey)
+
+	// 3. Revoke the key and verify that it can no longer be used.
+	assert.NoError(t, keys.Revoke(ctx, key.ID))
+	_, err = keys.Verify(ctx, token)
+	assert.ErrorIs(t, err, auth.ErrKeyRevoked)
+	assert.ErrorIs(t, keys.Revoke(ctx, key.ID), auth.ErrKeyRevoked)
+	assert.ErrorIs(t, keys.Revoke(ctx, "missing"), auth.ErrKeyNotFound)
+
+	// 4. Verify that the revoked key is still listed.
+	listed, err := keys.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, listed, 1)
+	assert.True(t, listed[0].Revoked())
+}
+
+func TestKeyManagerAuthenticate(t *testing.T) {
+	keys := auth.NewKeyManager(auth.NewMemoryKeyStore())
+	key, token, err := keys.Create(context.Background(), "reader", []string{"reader"})
+	assert.NoError(t, err)
+
+	// 1. A request without a bearer token is unauthenticated.
+	r, err := http.NewRequest("GET", "/list", nil)
+	assert.NoError(t, err)
+	_, err = keys.Authenticate(r)
+	assert.ErrorIs(t, err, auth.ErrUnauthenticated)
+
+	// 2. A request with the token authenticates as the key.
+	r.Header.Set("Authorization", "Bearer "+token)
+	id, err := keys.Authenticate(r)
+	assert.NoError(t, err)
+	assert.Equal(t, auth.Identity{Subject: "reader", KeyID: key.ID, Scopes: []auth.Scope{auth.ScopeRead}}, id)
+}
+
+func TestParseScopes(t *testing.T) {
+	// 1. Roles expand to their scopes and duplicates are removed.
+	scopes, err := auth.ParseScopes([]string{"editor", "read"})
+	assert.NoError(t, err)
+	assert.Equal(t, []auth.Scope{auth.ScopeDelete, auth.ScopeRead, auth.ScopeWrite}, scopes)
+
+	// 2. Unknown names are rejected.
+	_, err = auth.ParseScopes([]string{"superuser"})
+	assert.ErrorIs(t, err, auth.ErrInvalidScope)
+}
+
+func TestIdentityHas(t *testing.T) {
+	reader := auth.Identity{Scopes: []auth.Scope{auth.ScopeRead}}
+	assert.True(t, reader.Has(auth.ScopeRead))
+	assert.False(t, reader.Has(auth.ScopeDelete))
+
+	admin := auth.Identity{Scopes: []auth.Scope{auth.ScopeAdmin}}
+	assert.True(t, admin.Has(auth.ScopeDelete), "The admin scope grants every scope")
+}
+
+func TestCreateKeyRequiresScopes(t *testing.T) {
+	keys := auth.NewKeyManager(auth.NewMemoryKeyStore())
+	_, _, err := keys.Create(context.Background(), "nothing", nil)
+	assert.ErrorIs(t, err, auth.ErrInvalidScope)
+}
+
+// signJWT signs claims with key using alg, setting kid in the header when not empty.
+func signJWT(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		assert.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		assert.NoError(t, err)
+		signature = sig
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		assert.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims returns claims accepted by a verifier expecting the test issuer and audience.
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   "alice",
+		"iss":   "https://issuer.test",
+		"aud":   []string{"dictionary"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"dictionary-editors", "unrelated"},
+	}
+}
+
+// jwtOptions are the verifier options used with validClaims.
+var jwtOptions = auth.JWTOptions{
+	Issuer:      "https://issuer.test",
+	Audience:    "dictionary",
+	Leeway:      30 * time.Second,
+	RoleMapping: map[string]string{"dictionary-editors": "editor"},
+}
+
+func TestJWTVerifierAlgorithms(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	// 1. Write the RSA and EC public keys to a JWKS file and load it with the HMAC secret.
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64([]byte{1, 0, 1})},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+	}}
+	data, err := json.Marshal(jwks)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, data, 0600))
+
+	keys, err := auth.LoadJWKS(path)
+	assert.NoError(t, err)
+	verifier, err := auth.NewJWTVerifier(append(keys, auth.JWTKey{Key: secret}), jwtOptions)
+	assert.NoError(t, err)
+
+	// 2. Verify tokens signed with each algorithm and check the mapped identity.
+	tokens := map[string]string{
+		auth.AlgRS256: signJWT(t, auth.AlgRS256, "rsa-1", rsaKey, validClaims()),
+		auth.AlgES256: signJWT(t, auth.AlgES256, "ec-1", ecKey, validClaims()),
+		auth.AlgHS256: signJWT(t, auth.AlgHS256, "", secret, validClaims()),
+	}
+	for alg, token := range tokens {
+		r, err := http.NewRequest("GET", "/list", nil)
+		assert.NoError(t, err)
+		r.Header.Set("Authorization", "Bearer "+token)
+
+		id, err := verifier.Authenticate(r)
+		assert.NoError(t, err, alg)
+		assert.Equal(t, "alice", id.Subject, alg)
+		assert.Equal(t, []auth.Scope{auth.ScopeDelete, auth.ScopeRead, auth.ScopeWrite}, id.Scopes, alg)
+	}
+
+	// 3. A token whose algorithm does not match the key type is rejected.
+	confused := signJWT(t, auth.AlgHS256, "rsa-1", rsaKey.N.Bytes(), validClaims())
+	_, err = verifier.Verify(confused)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestJWTVerifierRejectsWeakOrMismatchedKeys(t *testing.T) {
+	weakKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	assert.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	// 1. RSA keys shorter than 2048 bits are refused, from a JWKS file or directly.
+	b64 := base64.RawURLEncoding.EncodeToString
+	data, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "weak", "n": b64(weakKey.N.Bytes()), "e": b64([]byte{1, 0, 1})},
+	}})
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, data, 0600))
+	_, err = auth.LoadJWKS(path)
+	assert.ErrorContains(t, err, "at least 2048 bits")
+
+	_, err = auth.NewJWTVerifier([]auth.JWTKey{{Key: &weakKey.PublicKey}}, jwtOptions)
+	assert.Error(t, err)
+
+	// 2. The algorithm pinned by a key must match its type.
+	_, err = auth.NewJWTVerifier([]auth.JWTKey{{ID: "rsa-1", Key: &rsaKey.PublicKey, Alg: auth.AlgHS256}}, jwtOptions)
+	assert.Error(t, err)
+	verifier, err := auth.NewJWTVerifier([]auth.JWTKey{{ID: "rsa-1", Key: &rsaKey.PublicKey, Alg: auth.AlgRS256}}, jwtOptions)
+	assert.NoError(t, err)
+	_, err = verifier.Verify(signJWT(t, auth.AlgRS256, "rsa-1", rsaKey, validClaims()))
+	assert.NoError(t, err)
+
+	// 3. Tokens naming another algorithm, including "none", find no key.
+	for _, alg := range []string{auth.AlgHS256, auth.AlgES256, "none"} {
+		token := signJWT(t, alg, "rsa-1", rsaKey.N.Bytes(), validClaims())
+		_, err = verifier.Verify(token)
+		assert.ErrorIs(t, err, auth.ErrInvalidToken, alg)
+	}
+}
+
+func TestJWTVerifierClaims(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	// 1. Load the public key from a PEM file.
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "key.pem")
+	assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	key, err := auth.LoadPEMKey(path)
+	assert.NoError(t, err)
+	verifier, err := auth.NewJWTVerifier([]auth.JWTKey{key}, jwtOptions)
+	assert.NoError(t, err)
+
+	// 2. Verify that each invalid claim is rejected, and clock skew within the leeway is tolerated.
+	cases := map[string]func(claims map[string]interface{}){
+		"expired":       func(c map[string]interface{}) { c["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"not yet valid": func(c map[string]interface{}) { c["nbf"] = time.Now().Add(time.Minute).Unix() },
+		"wrong issuer":  func(c map[string]interface{}) { c["iss"] = "https://other.test" },
+		"wrong aud":     func(c map[string]interface{}) { c["aud"] = "other" },
+		"missing exp":   func(c map[string]interface{}) { delete(c, "exp") },
+	}
+	for name, change := range cases {
+		claims := validClaims()
+		change(claims)
+		_, err := verifier.Verify(signJWT(t, auth.AlgRS256, "", rsaKey, claims))
+		assert.ErrorIs(t, err, auth.ErrInvalidToken, name)
+	}
+
+	skewed := validClaims()
+	skewed["exp"] = time.Now().Add(-10 * time.Second).Unix()
+	skewed["nbf"] = time.Now().Add(10 * time.Second).Unix()
+	_, err = verifier.Verify(signJWT(t, auth.AlgRS256, "", rsaKey, skewed))
+	assert.NoError(t, err)
+
+	// 3. Tokens without a subject are rejected, as they would share one identity.
+	for _, subject := range []interface{}{nil, "", "  ", 42} {
+		claims := validClaims()
+		claims["sub"] = subject
+		if subject == nil {
+			delete(claims, "sub")
+		}
+		r := httptest.NewRequest("GET", "/list", nil)
+		r.Header.Set("Authorization", "Bearer "+signJWT(t, auth.AlgRS256, "", rsaKey, claims))
+		_, err := verifier.Authenticate(r)
+		assert.ErrorIs(t, err, auth.ErrUnauthenticated, subject)
+	}
+
+	// 4. Unsigned tokens are rejected.
+	unsigned := strings.Join(strings.Split(signJWT(t, auth.AlgRS256, "", rsaKey, validClaims()), ".")[:2], ".") + "."
+	_, err = verifier.Verify(unsigned)
+	assert.ErrorIs(t, err, auth.ErrInvalidToken)
+}
+
+func TestClientCertAuthenticator(t *testing.T) {
+	// 1. Map the "ops" unit to the admin role.
+	a, err := auth.NewClientCertAuthenticator(map[string]string{"ops": "admin", "bob": "reader"})
+	assert.NoError(t, err)
+
+	request := func(cn string, units ...string) *http.Request {
+		r := httptest.NewRequest("GET", "/list", nil)
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn, OrganizationalUnit: units}}
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		return r
+	}
+
+	// 2. Callers are named by common name and granted the roles of their name and units.
+	id, err := a.Authenticate(request("alice", "ops"))
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", id.Subject)
+	assert.True(t, id.Has(auth.ScopeAdmin))
+
+	id, err = a.Authenticate(request("bob"))
+	assert.NoError(t, err)
+	assert.True(t, id.Has(auth.ScopeRead))
+	assert.False(t, id.Has(auth.ScopeWrite))
+
+	// 3. Requests without a verified certificate carry no credentials.
+	_, err = a.Authenticate(httptest.NewRequest("GET", "/list", nil))
+	assert.ErrorIs(t, err, auth.ErrNoCredentials)
+
+	// 4. Unknown roles are rejected.
+	_, err = auth.NewClientCertAuthenticator(map[string]string{"ops": "root"})
+	assert.ErrorIs(t, err, auth.ErrInvalidScope)
+}
+
+func TestChain(t *testing.T) {
+	// 1. Chain API keys with client certificates.
+	keys := auth.NewKeyManager(auth.NewMemoryKeyStore())
+	_, token, err := keys.Create(context.Background(), "key", []string{"editor"})
+	assert.NoError(t, err)
+	certs, err := auth.NewClientCertAuthenticator(nil)
+	assert.NoError(t, err)
+	a := auth.Chain(keys, certs)
+
+	withCert := func() *http.Request {
+		r := httptest.NewRequest("GET", "/list", nil)
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "alice"}}}}}
+		return r
+	}
+
+	// 2. A bearer token is used first, a certificate otherwise.
+	r := withCert()
+	r.Header.Set("Authorization", "Bearer "+token)
+	id, err := a.Authenticate(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "key", id.Subject)
+
+	id, err = a.Authenticate(withCert())
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", id.Subject)
+
+	// 3. An invalid token is rejected even with a certificate.
+	r = withCert()
+	r.Header.Set("Authorization", "Bearer dk_bad")
+	_, err = a.Authenticate(r)
+	assert.ErrorIs(t, err, auth.ErrUnauthenticated)
+	assert.NotErrorIs(t, err, auth.ErrNoCredentials)
+
+	// 4. Without any credentials, the chain reports none.
+	_, err = a.Authenticate(httptest.NewRequest("GET", "/list", nil))
+	assert.ErrorIs(t, err, auth.ErrNoCredentials)
+}
@@ -0,0 +1,67 @@
+// auth/identity.go
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrUnauthenticated is returned when a request carries no valid credentials.
+var ErrUnauthenticated = errors.New("unauthenticated")
+
+// ErrNoCredentials is returned when a request carries no credentials an
+// Authenticator understands. It matches ErrUnauthenticated.
+var ErrNoCredentials = fmt.Errorf("%w: no credentials", ErrUnauthenticated)
+
+// Identity is the authenticated caller of a request.
+type Identity struct {
+	// Subject names the caller, e.g. the API key name.
+	Subject string `json:"subject"`
+	// KeyID is the ID of the API key used, if any.
+	KeyID string `json:"key_id,omitempty"`
+	// Scopes lists the permissions granted to the caller.
+	Scopes []Scope `json:"scopes"`
+	// Anonymous is set for callers that sent no credentials to a public route.
+	Anonymous bool `json:"anonymous,omitempty"`
+}
+
+// AnonymousIdentity is the identity of unauthenticated callers of public routes.
+// It may only read.
+var AnonymousIdentity = Identity{Subject: "anonymous", Scopes: []Scope{ScopeRead}, Anonymous: true}
+
+// Has reports whether the identity is granted scope s.
+func (id Identity) Has(s Scope) bool {
+	return HasScope(id.Scopes, s)
+}
+
+// Authenticator authenticates HTTP requests. Rejected credentials give an error
+// matching ErrUnauthenticated; other errors mean that the credentials could not
+// be checked, e.g. because the key store is unavailable.
+type Authenticator interface {
+	Authenticate(r *http.Request) (Identity, error)
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of ctx carrying id.
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// IdentityFromContext returns the identity stored in ctx, if any.
+func IdentityFromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(identityKey{}).(Identity)
+	return id, ok
+}
+
+// BearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func BearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
@@ -0,0 +1,341 @@
+// auth/jwt.go
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidToken is returned when a JWT is malformed, badly signed or not valid now.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Signing algorithms accepted by the JWT verifier.
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgHS256 = "HS256"
+)
+
+// MinRSAKeyBits is the smallest RSA modulus accepted for verifying tokens.
+const MinRSAKeyBits = 2048
+
+// JWTKey is a key that can verify JWT signatures: an *rsa.PublicKey of at least
+// MinRSAKeyBits, an *ecdsa.PublicKey on P-256, or an HMAC secret as []byte.
+type JWTKey struct {
+	// ID matches the "kid" header of tokens; empty IDs match any token.
+	ID  string
+	Key interface{}
+	// Alg pins the algorithm of the tokens the key verifies. It defaults to the
+	// algorithm of the key type, and must match it when set.
+	Alg string
+}
+
+// JWTOptions configures the claims checked by a JWTVerifier.
+type JWTOptions struct {
+	// Issuer, when set, must equal the "iss" claim.
+	Issuer string
+	// Audience, when set, must be one of the "aud" claim values.
+	Audience string
+	// Leeway tolerates clock skew when checking "exp" and "nbf".
+	Leeway time.Duration
+	// RolesClaim names the claim holding roles or scopes, as an array or a
+	// space-separated string. It defaults to "roles".
+	RolesClaim string
+	// RoleMapping maps claim values to role or scope names, e.g.
+	// "dictionary-editors" to "editor". Unmapped values are used as is.
+	RoleMapping map[string]string
+}
+
+// JWTVerifier authenticates requests carrying a JWT bearer token.
+type JWTVerifier struct {
+	keys    []JWTKey
+	options JWTOptions
+	parser  *jwt.Parser
+	now     func() time.Time
+}
+
+// NewJWTVerifier creates a verifier trusting the given keys.
+func NewJWTVerifier(keys []JWTKey, opts JWTOptions) (*JWTVerifier, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("at least one JWT key is required")
+	}
+	keys = append([]JWTKey(nil), keys...)
+	var algorithms []string
+	for i, k := range keys {
+		alg, err := algorithmFor(k.Key)
+		if err != nil {
+			return nil, err
+		}
+		if k.Alg != "" && k.Alg != alg {
+			return nil, fmt.Errorf("JWT key %q: algorithm %s does not match its %T key", k.ID, k.Alg, k.Key)
+		}
+		keys[i].Alg = alg
+		if !containsString(algorithms, alg) {
+			algorithms = append(algorithms, alg)
+		}
+	}
+	if opts.RolesClaim == "" {
+		opts.RolesClaim = "roles"
+	}
+
+	v := &JWTVerifier{keys: keys, options: opts, now: time.Now}
+	parserOptions := []jwt.ParserOption{
+		jwt.WithValidMethods(algorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(opts.Leeway),
+		jwt.WithTimeFunc(func() time.Time { return v.now() }),
+	}
+	if opts.Issuer != "" {
+		parserOptions = append(parserOptions, jwt.WithIssuer(opts.Issuer))
+	}
+	if opts.Audience != "" {
+		parserOptions = append(parserOptions, jwt.WithAudience(opts.Audience))
+	}
+	v.parser = jwt.NewParser(parserOptions...)
+	return v, nil
+}
+
+// Authenticate verifies the bearer token of r as a JWT and maps its roles claim to
+// scopes. The token must name its subject, which identifies the caller in rate
+// limits, quotas and logs.
+func (v *JWTVerifier) Authenticate(r *http.Request) (Identity, error) {
+	token, ok := BearerToken(r)
+	if !ok {
+		return Identity{}, ErrNoCredentials
+	}
+
+	claims, err := v.Verify(token)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%w: %v", ErrUnauthenticated, err)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if strings.TrimSpace(subject) == "" {
+		return Identity{}, fmt.Errorf("%w: %w: missing sub claim", ErrUnauthenticated, ErrInvalidToken)
+	}
+	return Identity{Subject: subject, Scopes: v.scopes(claims[v.options.RolesClaim])}, nil
+}
+
+// Verify checks the signature and the time, issuer and audience claims of token,
+// and returns its claims.
+func (v *JWTVerifier) Verify(token string) (map[string]interface{}, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keysFor); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+// keysFor returns the keys that may have signed token: those pinned to its
+// algorithm, so that an RSA public key is never used as an HMAC secret, and
+// matching its "kid" header.
+func (v *JWTVerifier) keysFor(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	var set jwt.VerificationKeySet
+	for _, k := range v.keys {
+		if k.Alg != token.Method.Alg() || (kid != "" && k.ID != "" && k.ID != kid) {
+			continue
+		}
+		set.Keys = append(set.Keys, k.Key)
+	}
+	if len(set.Keys) == 0 {
+		return nil, fmt.Errorf("no %s key matches kid %q", token.Method.Alg(), kid)
+	}
+	return set, nil
+}
+
+// scopes maps the values of the roles claim to scopes, ignoring unknown values.
+func (v *JWTVerifier) scopes(claim interface{}) []Scope {
+	var names []string
+	for _, value := range claimValues(claim) {
+		if mapped, ok := v.options.RoleMapping[value]; ok {
+			value = mapped
+		}
+		if _, err := ParseScopes([]string{value}); err == nil {
+			names = append(names, value)
+		}
+	}
+
+	scopes, _ := ParseScopes(names)
+	return scopes
+}
+
+// claimValues returns the strings of a claim that is a string array or a
+// space-separated string.
+func claimValues(claim interface{}) []string {
+	switch c := claim.(type) {
+	case string:
+		return strings.Fields(c)
+	case []interface{}:
+		var values []string
+		for _, item := range c {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// containsString reports whether values contains s.
+func containsString(values []string, s string) bool {
+	for _, value := range values {
+		if value == s {
+			return true
+		}
+	}
+	return false
+}
+
+// algorithmFor returns the signing algorithm verified by key.
+func algorithmFor(key interface{}) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < MinRSAKeyBits {
+			return "", fmt.Errorf("RSA keys must be at least %d bits long, got %d", MinRSAKeyBits, k.N.BitLen())
+		}
+		return AlgRS256, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", errors.New("only P-256 ECDSA keys are supported")
+		}
+		return AlgES256, nil
+	case []byte:
+		if len(k) < 32 {
+			return "", errors.New("HMAC secrets must be at least 32 bytes long")
+		}
+		return AlgHS256, nil
+	}
+	return "", fmt.Errorf("unsupported JWT key type %T", key)
+}
+
+// LoadPEMKey reads an RSA or ECDSA public key, or a certificate holding one, from a PEM file.
+func LoadPEMKey(path string) (JWTKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return JWTKey{}, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return JWTKey{}, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return JWTKey{}, fmt.Errorf("%s: %v", path, err)
+		}
+		key = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return JWTKey{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return JWTKey{Key: key}, nil
+}
+
+// jwk is a JSON Web Key as found in a JWKS document.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// LoadJWKS reads the RSA, EC (P-256) and symmetric signing keys of a JWKS file.
+// Keys meant for encryption are skipped; the "alg" of a key pins its algorithm.
+func LoadJWKS(path string) ([]JWTKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	var keys []JWTKey
+	for i, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("%s: key %d: %v", path, i, err)
+		}
+		if _, err := algorithmFor(key); err != nil {
+			return nil, fmt.Errorf("%s: key %d: %v", path, i, err)
+		}
+		keys = append(keys, JWTKey{ID: k.Kid, Key: key, Alg: k.Alg})
+	}
+	return keys, nil
+}
+
+// publicKey converts the JWK to a key usable by the verifier.
+func (k jwk) publicKey() (interface{}, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 || exponent.Bit(0) == 0 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "oct":
+		return decode(k.K)
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,163 @@
+// auth/keystore.go
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoKeyStore is a KeyStore backed by a MongoDB collection.
+type MongoKeyStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoKeyStore creates a KeyStore using the given collection.
+func NewMongoKeyStore(collection *mongo.Collection) *MongoKeyStore {
+	return &MongoKeyStore{collection: collection}
+}
+
+// Insert stores a new key.
+func (s *MongoKeyStore) Insert(ctx context.Context, key APIKey) error {
+	_, err := s.collection.InsertOne(ctx, key)
+	if err != nil {
+		return fmt.Errorf("error storing api key: %w", err)
+	}
+	return nil
+}
+
+// Find returns the key with the given ID.
+func (s *MongoKeyStore) Find(ctx context.Context, id string) (APIKey, error) {
+	var key APIKey
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&key)
+	if err == mongo.ErrNoDocuments {
+		return APIKey{}, ErrKeyNotFound
+	}
+	if err != nil {
+		return APIKey{}, fmt.Errorf("error finding api key: %w", err)
+	}
+	return key, nil
+}
+
+// List returns every key ordered by creation time.
+func (s *MongoKeyStore) List(ctx context.Context) ([]APIKey, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, fmt.Errorf("error listing api keys: %w", err)
+	}
+
+	keys := []APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, fmt.Errorf("error listing api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke marks the key with the given ID as revoked.
+func (s *MongoKeyStore) Revoke(ctx context.Context, id string, at time.Time) error {
+	result, err := s.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": at}},
+	)
+	if err != nil {
+		return fmt.Errorf("error revoking api key: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		if _, err := s.Find(ctx, id); err != nil {
+			return err
+		}
+		return ErrKeyRevoked
+	}
+	return nil
+}
+
+// Touch records that the key with the given ID was used.
+func (s *MongoKeyStore) Touch(ctx context.Context, id string, at time.Time) error {
+	_, err := s.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"last_used_at": at}})
+	return err
+}
+
+// MemoryKeyStore is an in-process KeyStore, for tests and development.
+type MemoryKeyStore struct {
+	mu   sync.Mutex
+	keys map[string]APIKey
+}
+
+// NewMemoryKeyStore creates an empty MemoryKeyStore.
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{keys: map[string]APIKey{}}
+}
+
+// Insert stores a new key.
+func (s *MemoryKeyStore) Insert(ctx context.Context, key APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.keys[key.ID]; exists {
+		return fmt.Errorf("api key %s already exists", key.ID)
+	}
+	s.keys[key.ID] = key
+	return nil
+}
+
+// Find returns the key with the given ID.
+func (s *MemoryKeyStore) Find(ctx context.Context, id string) (APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return APIKey{}, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// List returns every key ordered by creation time.
+func (s *MemoryKeyStore) List(ctx context.Context) ([]APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+// Revoke marks the key with the given ID as revoked.
+func (s *MemoryKeyStore) Revoke(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if key.Revoked() {
+		return ErrKeyRevoked
+	}
+	key.RevokedAt = at
+	s.keys[id] = key
+	return nil
+}
+
+// Touch records that the key with the given ID was used.
+func (s *MemoryKeyStore) Touch(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrKeyNotFound
+	}
+	key.LastUsedAt = at
+	s.keys[id] = key
+	return nil
+}
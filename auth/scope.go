@@ -0,0 +1,67 @@
+// auth/scope.go
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Scope is a permission granted to a credential.
+type Scope string
+
+// Scopes enforced by the routes of the server.
+const (
+	ScopeRead   Scope = "read"
+	ScopeWrite  Scope = "write"
+	ScopeDelete Scope = "delete"
+	// ScopeAdmin grants every other scope as well.
+	ScopeAdmin Scope = "admin"
+)
+
+// ErrInvalidScope is returned for an unknown scope or role name.
+var ErrInvalidScope = errors.New("invalid scope")
+
+// Roles maps role names to the scopes they grant.
+var Roles = map[string][]Scope{
+	"reader": {ScopeRead},
+	"editor": {ScopeRead, ScopeWrite, ScopeDelete},
+	"admin":  {ScopeAdmin},
+}
+
+// ParseScopes expands role and scope names into a sorted, de-duplicated list of scopes.
+func ParseScopes(names []string) ([]Scope, error) {
+	set := map[Scope]bool{}
+	for _, name := range names {
+		if scopes, ok := Roles[name]; ok {
+			for _, s := range scopes {
+				set[s] = true
+			}
+			continue
+		}
+
+		switch s := Scope(name); s {
+		case ScopeRead, ScopeWrite, ScopeDelete, ScopeAdmin:
+			set[s] = true
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, name)
+		}
+	}
+
+	scopes := make([]Scope, 0, len(set))
+	for s := range set {
+		scopes = append(scopes, s)
+	}
+	sort.Slice(scopes, func(i, j int) bool { return scopes[i] < scopes[j] })
+	return scopes, nil
+}
+
+// HasScope reports whether scopes grant s. The admin scope grants everything.
+func HasScope(scopes []Scope, s Scope) bool {
+	for _, granted := range scopes {
+		if granted == s || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
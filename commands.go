@@ -0,0 +1,236 @@
+// commands.go
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"estiam/analytics"
+	"estiam/auth"
+	"estiam/config"
+	"estiam/dictionary"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// commandUsage describes the subcommands accepted after the configuration flags.
+const commandUsage = `usage:
+  estiam [flags]                    run the server
+  estiam [flags] keys create NAME ROLE...
+                                    create an API key granting roles (reader,
+                                    editor, admin) or scopes (read, write,
+                                    delete, admin) and print its token
+  estiam [flags] keys list          list API keys
+  estiam [flags] keys revoke ID     revoke an API key
+  estiam [flags] reindex            recompute the normalized keys and lemmas
+                                    used by lookup fallbacks, e.g. for entries
+                                    stored by older versions or after changing
+                                    dictionary.strip_accents or validation.language
+  estiam [flags] dedupe             remove the entries of words stored more than
+                                    once by older versions, keeping the oldest
+                                    one, so that words can be indexed as unique
+  estiam [flags] analyze [-json] [-top N] [-bucket DURATION] [FILE...]
+                                    report top words, misses, latency
+                                    percentiles and traffic from access logs
+                                    (default: log.file; .gz files are read too)`
+
+// commandTimeout bounds how long a subcommand may wait on the database.
+const commandTimeout = 30 * time.Second
+
+// runCommand runs the subcommand given by args and writes its output to out.
+func runCommand(d *dictionary.Dictionary, keys *auth.KeyManager, args []string, out io.Writer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	switch args[0] {
+	case "keys":
+		return runKeys(ctx, keys, args[1:], out)
+	case "reindex":
+		// Reindexing reads the whole collection, so commandTimeout does not bound it.
+		return runReindex(context.Background(), d, args[1:], out)
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], commandUsage)
+	}
+}
+
+// runKeys runs the "keys" subcommand.
+func runKeys(ctx context.Context, keys *auth.KeyManager, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing keys action\n%s", commandUsage)
+	}
+
+	switch {
+	case args[0] == "create" && len(args) >= 3:
+		key, token, err := keys.Create(ctx, args[1], args[2:])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Created API key %s (%s). Store this token now; it is not shown again:\n%s\n", key.ID, key.Name, token)
+		return nil
+
+	case args[0] == "list" && len(args) == 1:
+		list, err := keys.List(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tSCOPES\tCREATED\tLAST USED\tREVOKED")
+		for _, key := range list {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, formatScopes(key.Scopes),
+				formatTime(key.CreatedAt), formatTime(key.LastUsedAt), formatTime(key.RevokedAt))
+		}
+		return tw.Flush()
+
+	case args[0] == "revoke" && len(args) == 2:
+		if err := keys.Revoke(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Revoked API key %s\n", args[1])
+		return nil
+	}
+
+	return fmt.Errorf("invalid keys command\n%s", commandUsage)
+}
+
+// runReindex runs the "reindex" subcommand.
+func runReindex(ctx context.Context, d *dictionary.Dictionary, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("invalid reindex command\n%s", commandUsage)
+	}
+
+	updated, err := d.Reindex(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Reindexed %d entries\n", updated)
+	return nil
+}
+
+// runDedupe runs the "dedupe" subcommand. It connects to the database itself,
+// since the dictionary cannot be initialized over duplicate words.
+func runDedupe(cfg config.MongoConfig, opts dictionary.Options, args []string, out io.Writer) error {
+	if len(args) > 0 {
+		return fmt.Errorf("invalid dedupe command\n%s", commandUsage)
+	}
+
+	// Deduplicating reads the whole collection, so commandTimeout does not bound it.
+	removed, err := dictionary.Dedupe(context.Background(), cfg.URI, cfg.Database, cfg.Collection, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Removed %d duplicate entries\n", removed)
+	return nil
+}
+
+// runAnalyze runs the "analyze" subcommand over the given access logs, or over
+// defaultFile when none is given. It does not need the database.
+func runAnalyze(defaultFile string, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	topN := fs.Int("top", 10, "number of words and misses to list")
+	bucket := fs.Duration("bucket", time.Hour, "width of the traffic buckets")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{defaultFile}
+	}
+
+	analyzer := analytics.NewAnalyzer(analytics.Options{Top: *topN, Bucket: *bucket})
+	for _, path := range files {
+		if err := analyzeFile(analyzer, path); err != nil {
+			return err
+		}
+	}
+	report := analyzer.Report()
+
+	if *asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+	return printReport(out, report)
+}
+
+// analyzeFile adds the records of an access log, possibly gzipped, to analyzer.
+func analyzeFile(analyzer *analytics.Analyzer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	if err := analyzer.Read(r); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// printReport prints report as tables.
+func printReport(out io.Writer, report analytics.Report) error {
+	tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Requests: %d (%d other lines skipped)\n", report.Requests, report.Skipped)
+	if report.Requests > 0 {
+		fmt.Fprintf(tw, "Period: %s to %s\n", formatTime(report.From), formatTime(report.To))
+	}
+
+	fmt.Fprintln(tw, "\nTOP WORDS\tLOOKUPS")
+	for _, c := range report.TopWords {
+		fmt.Fprintf(tw, "%s\t%d\n", c.Word, c.Count)
+	}
+
+	fmt.Fprintln(tw, "\nMISSES (404)\tLOOKUPS")
+	for _, c := range report.Misses {
+		fmt.Fprintf(tw, "%s\t%d\n", c.Word, c.Count)
+	}
+
+	fmt.Fprintln(tw, "\nROUTE\tREQUESTS\tP50 (ms)\tP95 (ms)\tP99 (ms)")
+	for _, l := range report.Latency {
+		route := l.Route
+		if route == "" {
+			route = "-" // legacy lines with an empty URI
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%.3f\t%.3f\t%.3f\n", route, l.Requests, l.P50MS, l.P95MS, l.P99MS)
+	}
+
+	fmt.Fprintln(tw, "\nPERIOD\tREQUESTS\tERRORS (5xx)")
+	for _, t := range report.Traffic {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", formatTime(t.Start), t.Requests, t.Errors)
+	}
+	return tw.Flush()
+}
+
+// formatScopes formats scopes as a comma-separated list.
+func formatScopes(scopes []auth.Scope) string {
+	names := make([]string, len(scopes))
+	for i, s := range scopes {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ",")
+}
+
+// formatTime formats t for command output, showing "-" for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
@@ -0,0 +1,441 @@
+// config/config.go
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config is the effective configuration of the dictionary server.
+type Config struct {
+	Server     ServerConfig     `json:"server"`
+	Mongo      MongoConfig      `json:"mongo"`
+	Log        LogConfig        `json:"log"`
+	Auth       AuthConfig       `json:"auth"`
+	RateLimit  RateLimitConfig  `json:"rate_limit"`
+	Dictionary DictionaryConfig `json:"dictionary"`
+	Validation ValidationConfig `json:"validation"`
+}
+
+// ServerConfig configures the HTTP server.
+type ServerConfig struct {
+	Addr string `json:"addr"`
+	// TrustedProxies lists the IP addresses or CIDR prefixes of the reverse proxies
+	// whose X-Forwarded-For header names the client, for per-IP rate limits.
+	TrustedProxies []string `json:"trusted_proxies"`
+	// MetricsPath serves Prometheus metrics to admins; empty disables them.
+	MetricsPath string `json:"metrics_path"`
+	// MetricsAddr, when set, serves the metrics on a separate listener without
+	// authentication instead, e.g. on an address only reachable by the scrapers.
+	MetricsAddr string `json:"metrics_addr"`
+	// ReadinessTimeout bounds the dependency checks of /readyz.
+	ReadinessTimeout Duration `json:"readiness_timeout"`
+	// CacheMaxAge is how long clients and caches may reuse lookups and listings
+	// without revalidating them; 0 makes them revalidate every time.
+	CacheMaxAge Duration `json:"cache_max_age"`
+	// MaxBodyBytes bounds the size of request bodies; 0 disables the limit.
+	MaxBodyBytes int64 `json:"max_body_bytes"`
+	// ReadHeaderTimeout bounds reading the headers of a request.
+	ReadHeaderTimeout Duration `json:"read_header_timeout"`
+	// ReadTimeout bounds reading a whole request, body included.
+	ReadTimeout Duration `json:"read_timeout"`
+	// WriteTimeout bounds serving a request, from the end of its headers to the end of the response.
+	WriteTimeout Duration `json:"write_timeout"`
+	// IdleTimeout bounds how long a keep-alive connection waits for the next request.
+	IdleTimeout Duration `json:"idle_timeout"`
+	// CSVEscapeFormulas prefixes CSV cells starting like a spreadsheet formula with
+	// a quote, at the cost of altering them.
+	CSVEscapeFormulas bool `json:"csv_escape_formulas"`
+	// ShutdownTimeout bounds how long in-flight requests are drained on SIGINT or SIGTERM.
+	ShutdownTimeout Duration          `json:"shutdown_timeout"`
+	Compression     CompressionConfig `json:"compression"`
+	TLS             TLSConfig         `json:"tls"`
+}
+
+// CompressionConfig configures the compression of responses.
+type CompressionConfig struct {
+	// Enabled compresses responses with zstd or gzip, as clients accept.
+	Enabled bool `json:"enabled"`
+	// MinSize is the size, in bytes, below which responses are sent as is.
+	MinSize int `json:"min_size"`
+}
+
+// Client certificate policies.
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// TLSConfig configures HTTPS. The server serves plain HTTP when CertFile is empty.
+type TLSConfig struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+	// ReloadInterval is how often the certificate files are checked for changes.
+	ReloadInterval Duration `json:"reload_interval"`
+	// ClientAuth asks clients for certificates signed by ClientCAFile (mutual TLS):
+	// "none", "optional" or "require". Verified certificates authenticate requests
+	// as an alternative to bearer tokens.
+	ClientAuth   string `json:"client_auth"`
+	ClientCAFile string `json:"client_ca_file"`
+}
+
+// MongoConfig configures the MongoDB connection.
+type MongoConfig struct {
+	URI        string `json:"uri"`
+	Database   string `json:"database"`
+	Collection string `json:"collection"`
+}
+
+// LogConfig configures the access log.
+type LogConfig struct {
+	File string `json:"file"`
+	// Format is "json", "logfmt" or "combined" (Apache combined log format).
+	Format string `json:"format"`
+	// Level is the minimum level logged: "debug", "info", "warn" or "error".
+	Level string `json:"level"`
+	// MaxSizeMB rotates the file before it grows beyond this many megabytes; 0 disables it.
+	MaxSizeMB int64 `json:"max_size_mb"`
+	// RotateEvery rotates the file after this long; 0 disables it.
+	RotateEvery Duration `json:"rotate_every"`
+	// MaxAge removes rotated files older than this; 0 keeps them.
+	MaxAge Duration `json:"max_age"`
+	// MaxBackups keeps at most this many rotated files; 0 keeps them all.
+	MaxBackups int `json:"max_backups"`
+	// Compress gzips rotated files.
+	Compress bool `json:"compress"`
+}
+
+// Authentication modes.
+const (
+	AuthModeAPIKey = "apikey"
+	AuthModeJWT    = "jwt"
+)
+
+// AuthConfig configures authentication.
+type AuthConfig struct {
+	// Mode selects how bearer tokens are verified: "apikey" or "jwt".
+	Mode string `json:"mode"`
+	// PublicRead serves the read routes (lookups and listings) without credentials.
+	// Write and admin routes always require them.
+	PublicRead bool `json:"public_read"`
+	// KeysCollection is the MongoDB collection holding hashed API keys.
+	KeysCollection string    `json:"keys_collection"`
+	JWT            JWTConfig `json:"jwt"`
+	// ClientCertRoles maps the common name or an organizational unit of client
+	// certificates to role or scope names, when server.tls.client_auth is enabled.
+	ClientCertRoles map[string]string `json:"client_cert_roles"`
+}
+
+// JWTConfig configures the verification of JWT bearer tokens.
+// At least one of JWKSFile, PEMFile and HMACSecret must be set in jwt mode.
+type JWTConfig struct {
+	JWKSFile    string            `json:"jwks_file"`
+	PEMFile     string            `json:"pem_file"`
+	HMACSecret  Secret            `json:"hmac_secret"`
+	Issuer      string            `json:"issuer"`
+	Audience    string            `json:"audience"`
+	Leeway      Duration          `json:"leeway"`
+	RolesClaim  string            `json:"roles_claim"`
+	RoleMapping map[string]string `json:"role_mapping"`
+}
+
+// RateLimitConfig configures per-client rate limits by route class, and daily quotas.
+type RateLimitConfig struct {
+	Read          RateLimitRule `json:"read"`
+	AnonymousRead RateLimitRule `json:"anonymous_read"`
+	Write         RateLimitRule `json:"write"`
+	Admin         RateLimitRule `json:"admin"`
+	// AuthFailures limits failed authentications per client IP, whatever the route.
+	AuthFailures RateLimitRule `json:"auth_failures"`
+	// DailyQuota caps the requests of each API key or subject per UTC day; 0 disables it.
+	DailyQuota int64 `json:"daily_quota"`
+	// QuotasCollection is the MongoDB collection holding daily request counts.
+	QuotasCollection string `json:"quotas_collection"`
+	// QuotaFailOpen lets requests through when daily counts cannot be read or
+	// written; when false they are refused with 503 instead.
+	QuotaFailOpen bool `json:"quota_fail_open"`
+}
+
+// RateLimitRule is a token bucket refilled at PerMinute requests per minute,
+// holding up to Burst requests. A zero PerMinute disables the limit.
+type RateLimitRule struct {
+	PerMinute int `json:"per_minute"`
+	Burst     int `json:"burst"`
+}
+
+// DictionaryConfig configures lookups and per-operation deadlines.
+type DictionaryConfig struct {
+	StripAccents bool        `json:"strip_accents"`
+	Timeouts     Timeouts    `json:"timeouts"`
+	Cache        CacheConfig `json:"cache"`
+}
+
+// CacheConfig configures the in-process cache of lookups.
+type CacheConfig struct {
+	// Size is the maximum number of cached lookups; 0 disables the cache.
+	Size        int      `json:"size"`
+	TTL         Duration `json:"ttl"`
+	NegativeTTL Duration `json:"negative_ttl"`
+	// Watch invalidates the cache from the MongoDB change stream, so that changes
+	// made through other servers are seen before the TTL expires.
+	Watch bool `json:"watch"`
+}
+
+// Timeouts holds the per-operation deadlines of the dictionary.
+type Timeouts struct {
+	Connect Duration `json:"connect"`
+	Add     Duration `json:"add"`
+	Get     Duration `json:"get"`
+	Remove  Duration `json:"remove"`
+	List    Duration `json:"list"`
+}
+
+// ValidationConfig configures the validation of new entries.
+type ValidationConfig struct {
+	// Language restricts the scripts of words; "en" also enables lemma lookups.
+	Language            string `json:"language"`
+	MinWordLength       int    `json:"min_word_length"`
+	MaxWordLength       int    `json:"max_word_length"`
+	MinDefinitionLength int    `json:"min_definition_length"`
+	MaxDefinitionLength int    `json:"max_definition_length"`
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			MetricsPath:       "/metrics",
+			ReadinessTimeout:  Duration(2 * time.Second),
+			CacheMaxAge:       Duration(time.Minute),
+			MaxBodyBytes:      1 << 20,
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			ReadTimeout:       Duration(30 * time.Second),
+			WriteTimeout:      Duration(60 * time.Second),
+			IdleTimeout:       Duration(120 * time.Second),
+			ShutdownTimeout:   Duration(30 * time.Second),
+			Compression: CompressionConfig{
+				Enabled: true,
+				MinSize: 1024,
+			},
+			TLS: TLSConfig{
+				ReloadInterval: Duration(time.Minute),
+				ClientAuth:     ClientAuthNone,
+			},
+		},
+		Mongo: MongoConfig{
+			URI:        "mongodb://localhost:27017",
+			Database:   "dictionary",
+			Collection: "dictionary",
+		},
+		Log: LogConfig{File: "jornale.txt", Format: "json", Level: "info"},
+		Auth: AuthConfig{
+			Mode:           AuthModeAPIKey,
+			KeysCollection: "api_keys",
+			JWT: JWTConfig{
+				Leeway:     Duration(time.Minute),
+				RolesClaim: "roles",
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Read:             RateLimitRule{PerMinute: 600, Burst: 100},
+			AnonymousRead:    RateLimitRule{PerMinute: 60, Burst: 20},
+			Write:            RateLimitRule{PerMinute: 60, Burst: 10},
+			Admin:            RateLimitRule{PerMinute: 30, Burst: 10},
+			AuthFailures:     RateLimitRule{PerMinute: 10, Burst: 20},
+			QuotasCollection: "quotas",
+			QuotaFailOpen:    true,
+		},
+		Dictionary: DictionaryConfig{
+			Timeouts: Timeouts{
+				Connect: Duration(10 * time.Second),
+				Add:     Duration(5 * time.Second),
+				Get:     Duration(2 * time.Second),
+				Remove:  Duration(5 * time.Second),
+				List:    Duration(30 * time.Second),
+			},
+			Cache: CacheConfig{
+				Size:        10000,
+				TTL:         Duration(5 * time.Minute),
+				NegativeTTL: Duration(30 * time.Second),
+				Watch:       true,
+			},
+		},
+		Validation: ValidationConfig{
+			Language:            "en",
+			MinWordLength:       3,
+			MaxWordLength:       64,
+			MinDefinitionLength: 5,
+			MaxDefinitionLength: 2000,
+		},
+	}
+}
+
+// Validate checks that the configuration is complete and consistent.
+// It reports every problem found, not just the first one.
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	_, _, err := net.SplitHostPort(c.Server.Addr)
+	check(err == nil, "server.addr: %q is not a host:port address", c.Server.Addr)
+	if c.Server.MetricsAddr != "" {
+		_, _, err := net.SplitHostPort(c.Server.MetricsAddr)
+		check(err == nil, "server.metrics_addr: %q is not a host:port address", c.Server.MetricsAddr)
+		check(c.Server.MetricsAddr != c.Server.Addr, "server.metrics_addr: must differ from server.addr")
+		check(c.Server.MetricsPath != "", "server.metrics_addr: requires server.metrics_path")
+	}
+	for _, proxy := range c.Server.TrustedProxies {
+		_, err := netip.ParsePrefix(proxy)
+		if !strings.Contains(proxy, "/") {
+			_, err = netip.ParseAddr(proxy)
+		}
+		check(err == nil, "server.trusted_proxies: %q is not an IP address or CIDR prefix", proxy)
+	}
+	check(c.Server.ReadinessTimeout > 0, "server.readiness_timeout: must be positive")
+	check(c.Server.CacheMaxAge >= 0, "server.cache_max_age: must not be negative")
+	check(c.Server.MaxBodyBytes >= 0, "server.max_body_bytes: must not be negative")
+	check(c.Server.ReadHeaderTimeout >= 0 && c.Server.ReadTimeout >= 0 && c.Server.WriteTimeout >= 0 && c.Server.IdleTimeout >= 0,
+		"server: timeouts must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout: must be positive")
+	check(c.Server.Compression.MinSize >= 0, "server.compression.min_size: must not be negative")
+	tls := c.Server.TLS
+	check((tls.CertFile == "") == (tls.KeyFile == ""), "server.tls: cert_file and key_file must be set together")
+	check(tls.ReloadInterval > 0, "server.tls.reload_interval: must be positive")
+	check(tls.ClientAuth == ClientAuthNone || tls.ClientAuth == ClientAuthOptional || tls.ClientAuth == ClientAuthRequire,
+		"server.tls.client_auth: must be %q, %q or %q", ClientAuthNone, ClientAuthOptional, ClientAuthRequire)
+	if tls.ClientAuth != ClientAuthNone {
+		check(tls.CertFile != "", "server.tls.client_auth: requires cert_file and key_file")
+		check(tls.ClientCAFile != "", "server.tls.client_ca_file: must be set when client_auth is %q", tls.ClientAuth)
+	}
+	check(c.Server.MetricsPath == "" || strings.HasPrefix(c.Server.MetricsPath, "/"), "server.metrics_path: must start with /")
+
+	u, err := url.Parse(c.Mongo.URI)
+	check(err == nil && (u.Scheme == "mongodb" || u.Scheme == "mongodb+srv"), "mongo.uri: must be a mongodb:// or mongodb+srv:// URI")
+	check(c.Mongo.Database != "", "mongo.database: must not be empty")
+	check(c.Mongo.Collection != "", "mongo.collection: must not be empty")
+
+	check(c.Log.File != "", "log.file: must not be empty")
+	check(c.Log.Format == "json" || c.Log.Format == "logfmt" || c.Log.Format == "combined", "log.format: must be json, logfmt or combined")
+	check(c.Log.MaxSizeMB >= 0 && c.Log.RotateEvery >= 0 && c.Log.MaxAge >= 0 && c.Log.MaxBackups >= 0, "log: rotation settings must not be negative")
+	check(c.Log.Level == "debug" || c.Log.Level == "info" || c.Log.Level == "warn" || c.Log.Level == "error", "log.level: must be debug, info, warn or error")
+
+	check(c.Auth.KeysCollection != "", "auth.keys_collection: must not be empty")
+	check(c.Auth.Mode == AuthModeAPIKey || c.Auth.Mode == AuthModeJWT, "auth.mode: must be %q or %q", AuthModeAPIKey, AuthModeJWT)
+	if c.Auth.Mode == AuthModeJWT {
+		jwt := c.Auth.JWT
+		check(jwt.JWKSFile != "" || jwt.PEMFile != "" || jwt.HMACSecret != "", "auth.jwt: one of jwks_file, pem_file or hmac_secret must be set")
+		check(jwt.HMACSecret == "" || len(jwt.HMACSecret) >= 32, "auth.jwt.hmac_secret: must be at least 32 bytes long")
+		check(jwt.Leeway >= 0, "auth.jwt.leeway: must not be negative")
+		check(jwt.RolesClaim != "", "auth.jwt.roles_claim: must not be empty")
+	}
+
+	rules := map[string]RateLimitRule{
+		"read":           c.RateLimit.Read,
+		"anonymous_read": c.RateLimit.AnonymousRead,
+		"write":          c.RateLimit.Write,
+		"admin":          c.RateLimit.Admin,
+		"auth_failures":  c.RateLimit.AuthFailures,
+	}
+	for name, rule := range rules {
+		check(rule.PerMinute >= 0 && rule.Burst >= 0, "rate_limit.%s: must not be negative", name)
+		check(rule.PerMinute == 0 || rule.Burst >= 1, "rate_limit.%s.burst: must be at least 1", name)
+	}
+	check(c.RateLimit.DailyQuota >= 0, "rate_limit.daily_quota: must not be negative")
+	check(c.RateLimit.DailyQuota == 0 || c.RateLimit.QuotasCollection != "", "rate_limit.quotas_collection: must not be empty")
+
+	timeouts := map[string]Duration{
+		"connect": c.Dictionary.Timeouts.Connect,
+		"add":     c.Dictionary.Timeouts.Add,
+		"get":     c.Dictionary.Timeouts.Get,
+		"remove":  c.Dictionary.Timeouts.Remove,
+		"list":    c.Dictionary.Timeouts.List,
+	}
+	for name, d := range timeouts {
+		check(d >= 0, "dictionary.timeouts.%s: must not be negative", name)
+	}
+	cache := c.Dictionary.Cache
+	check(cache.Size >= 0, "dictionary.cache.size: must not be negative")
+	check(cache.Size == 0 || cache.TTL > 0, "dictionary.cache.ttl: must be positive when the cache is enabled")
+	check(cache.NegativeTTL >= 0, "dictionary.cache.negative_ttl: must not be negative")
+
+	v := c.Validation
+	check(v.MinWordLength >= 1, "validation.min_word_length: must be at least 1")
+	check(v.MaxWordLength == 0 || v.MaxWordLength >= v.MinWordLength, "validation.max_word_length: must be 0 or at least min_word_length")
+	check(v.MinDefinitionLength >= 1, "validation.min_definition_length: must be at least 1")
+	check(v.MaxDefinitionLength == 0 || v.MaxDefinitionLength >= v.MinDefinitionLength, "validation.max_definition_length: must be 0 or at least min_definition_length")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Redacted returns a copy of the configuration that is safe to display:
+// secrets are masked and the password is removed from the Mongo URI.
+func (c Config) Redacted() Config {
+	if u, err := url.Parse(c.Mongo.URI); err == nil {
+		c.Mongo.URI = u.Redacted()
+	}
+	if c.Auth.JWT.HMACSecret != "" {
+		c.Auth.JWT.HMACSecret = redacted
+	}
+	return c
+}
+
+// redacted replaces secret values in displayed configuration.
+const redacted = "REDACTED"
+
+// Secret is a configuration value that must never be displayed.
+// It formats as a mask; only Redacted configuration should be marshaled.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+// Duration is a time.Duration written as a string such as "5s" in configuration files.
+type Duration time.Duration
+
+// UnmarshalText parses a duration such as "250ms" or "5s".
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText formats the duration as a string such as "5s".
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalJSON accepts a duration string, or a number of nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var n int64
+	if err := json.Unmarshal(data, &n); err == nil {
+		*d = Duration(n)
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return d.UnmarshalText([]byte(s))
+}
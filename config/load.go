@@ -0,0 +1,214 @@
+// config/load.go
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix prefixes the environment variables overriding configuration values,
+// e.g. DICTIONARY_MONGO_URI overrides mongo.uri.
+const EnvPrefix = "DICTIONARY_"
+
+// ConfigFileEnv names the environment variable holding the configuration file path.
+const ConfigFileEnv = EnvPrefix + "CONFIG"
+
+// Load builds the configuration from, in increasing order of precedence, the
+// defaults, the configuration file, environment variables and command-line flags.
+// The file is given by the -config flag or the DICTIONARY_CONFIG variable.
+// lookupEnv is usually os.LookupEnv. The result is validated. Load also returns
+// the arguments left after the flags, such as a subcommand.
+func Load(name string, args []string, lookupEnv func(string) (string, bool)) (Config, []string, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := fs.String("config", "", "path to a YAML, TOML or JSON configuration file")
+	for _, f := range fields(&cfg) {
+		usage := fmt.Sprintf("overrides %s (env %s)", f.flagName(), f.envName())
+		if f.value.Kind() == reflect.Map {
+			usage += ", as key=value,key=value"
+		}
+		fs.String(f.flagName(), "", usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	path := *configFile
+	if path == "" {
+		path, _ = lookupEnv(ConfigFileEnv)
+	}
+	if path != "" {
+		if err := LoadFile(path, &cfg); err != nil {
+			return Config{}, nil, err
+		}
+	}
+
+	for _, f := range fields(&cfg) {
+		if value, ok := lookupEnv(f.envName()); ok {
+			if err := f.set(value); err != nil {
+				return Config{}, nil, fmt.Errorf("%s: %v", f.envName(), err)
+			}
+		}
+	}
+
+	var flagErr error
+	set := fields(&cfg)
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range set {
+			if f.flagName() == fl.Name && flagErr == nil {
+				if err := f.set(fl.Value.String()); err != nil {
+					flagErr = fmt.Errorf("-%s: %v", fl.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return Config{}, nil, flagErr
+	}
+
+	return cfg, fs.Args(), cfg.Validate()
+}
+
+// LoadFile decodes a YAML (.yaml, .yml), TOML (.toml) or JSON (.json) file over cfg.
+// Unknown keys are rejected so that typos do not go unnoticed.
+func LoadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var values map[string]interface{}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		err = toml.Unmarshal(data, &values)
+	case ".json":
+		err = json.Unmarshal(data, &values)
+	default:
+		return fmt.Errorf("%s: unsupported configuration format %q", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	// Re-encode the decoded values as JSON so every format shares the json tags.
+	normalized, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(normalized))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+// field is a leaf value of the configuration, addressed by its json tag path.
+type field struct {
+	path  []string
+	value reflect.Value
+}
+
+// flagName returns the dotted flag name of the field, e.g. "mongo.uri".
+func (f field) flagName() string {
+	return strings.Join(f.path, ".")
+}
+
+// envName returns the environment variable of the field, e.g. "DICTIONARY_MONGO_URI".
+func (f field) envName() string {
+	return EnvPrefix + strings.ToUpper(strings.Join(f.path, "_"))
+}
+
+// set parses s into the field according to its type.
+func (f field) set(s string) error {
+	if u, ok := f.value.Addr().Interface().(interface{ UnmarshalText([]byte) error }); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(n)
+	case reflect.Slice:
+		if f.value.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", f.value.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items).Convert(f.value.Type()))
+	case reflect.Map:
+		// Maps are written as comma-separated key=value pairs, e.g. "ops=admin,qa=reader".
+		t := f.value.Type()
+		if t.Key().Kind() != reflect.String || t.Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", t)
+		}
+		items := reflect.MakeMap(t)
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			key, value, ok := strings.Cut(item, "=")
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			if !ok || key == "" {
+				return fmt.Errorf("%q is not a key=value pair", item)
+			}
+			items.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), reflect.ValueOf(value).Convert(t.Elem()))
+		}
+		f.value.Set(items)
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+	return nil
+}
+
+// fields lists the leaf values of cfg in declaration order.
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value, path []string)
+	walk = func(v reflect.Value, path []string) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+			if name == "" || name == "-" {
+				continue
+			}
+			p := append(append([]string{}, path...), name)
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Struct {
+				walk(fv, p)
+				continue
+			}
+			out = append(out, field{path: p, value: fv})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem(), nil)
+	return out
+}
@@ -0,0 +1,168 @@
+package dictionary
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheOptions configures the lookup cache of a Dictionary.
+type CacheOptions struct {
+	// Size is the maximum number of cached lookups; 0 disables the cache.
+	Size int
+	// TTL is how long a found word is cached.
+	TTL time.Duration
+	// NegativeTTL is how long a missing word is cached; 0 disables negative caching.
+	NegativeTTL time.Duration
+}
+
+// CacheStats counts the activity of the lookup cache.
+type CacheStats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	Invalidations uint64
+	Entries       int
+}
+
+// lookupCache is a bounded LRU cache of lookups, found or missing, keyed by the
+// word looked up. An entry change invalidates every lookup it could affect:
+// those of its word, of its normalized key and of its lemma.
+type lookupCache struct {
+	options      CacheOptions
+	stripAccents bool
+	language     string
+	now          func() time.Time
+
+	mu    sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+	// gen counts invalidations, so that a lookup racing with a change is not cached.
+	gen   uint64
+	stats CacheStats
+}
+
+// cacheItem is a cached lookup.
+type cacheItem struct {
+	word, key, lemma string
+	match            Match
+	found            bool
+	expires          time.Time
+}
+
+// newLookupCache creates an empty cache, normalizing words like the dictionary.
+func newLookupCache(opts CacheOptions, stripAccents bool, language string) *lookupCache {
+	return &lookupCache{
+		options:      opts,
+		stripAccents: stripAccents,
+		language:     language,
+		now:          time.Now,
+		items:        map[string]*list.Element{},
+		lru:          list.New(),
+	}
+}
+
+// get returns the cached lookup of word, if any: its match and whether the word was found.
+func (c *lookupCache) get(word string) (Match, bool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[word]
+	if ok && c.now().After(elem.Value.(*cacheItem).expires) {
+		c.remove(elem)
+		ok = false
+	}
+	if !ok {
+		c.stats.Misses++
+		return Match{}, false, false
+	}
+
+	c.stats.Hits++
+	c.lru.MoveToFront(elem)
+	item := elem.Value.(*cacheItem)
+	return item.match, item.found, true
+}
+
+// generation returns the current generation, to be passed to add.
+func (c *lookupCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// add caches the lookup of word read at the given generation, unless an
+// invalidation happened since, evicting the least recently used lookup when full.
+func (c *lookupCache) add(gen uint64, word string, match Match, found bool) {
+	ttl := c.options.TTL
+	if !found {
+		ttl = c.options.NegativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+
+	key := NormalizeKey(word, c.stripAccents)
+	item := &cacheItem{word: word, key: key, lemma: LemmaFor(c.language, key), match: match, found: found, expires: c.now().Add(ttl)}
+	if elem, ok := c.items[word]; ok {
+		elem.Value = item
+		c.lru.MoveToFront(elem)
+		return
+	}
+
+	c.items[word] = c.lru.PushFront(item)
+	for c.lru.Len() > c.options.Size {
+		c.remove(c.lru.Back())
+		c.stats.Evictions++
+	}
+}
+
+// invalidate drops the lookups that a change to entry could affect: those of the
+// same word, normalized key or lemma, and those that matched the entry.
+func (c *lookupCache) invalidate(entry Entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+
+	for elem := c.lru.Front(); elem != nil; {
+		next := elem.Next()
+		item := elem.Value.(*cacheItem)
+		if (entry.Word != "" && item.word == entry.Word) || (entry.Key != "" && item.key == entry.Key) ||
+			(entry.Lemma != "" && item.lemma == entry.Lemma) || (!entry.ID.IsZero() && item.match.Entry.ID == entry.ID) {
+			c.remove(elem)
+			c.stats.Invalidations++
+		}
+		elem = next
+	}
+}
+
+// purge drops every cached lookup, e.g. when changes may have been missed.
+func (c *lookupCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+
+	c.stats.Invalidations += uint64(c.lru.Len())
+	c.items = map[string]*list.Element{}
+	c.lru.Init()
+}
+
+// remove drops one cached lookup; c.mu must be held.
+func (c *lookupCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.items, elem.Value.(*cacheItem).word)
+}
+
+// snapshot returns the statistics of the cache.
+func (c *lookupCache) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
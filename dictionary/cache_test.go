@@ -0,0 +1,118 @@
+// cache_test.go
+package dictionary
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestCache returns a cache whose clock is advanced by the returned function.
+func newTestCache(opts CacheOptions) (*lookupCache, func(time.Duration)) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	c := newLookupCache(opts, false, "en")
+	c.now = func() time.Time { return now }
+	return c, func(d time.Duration) { now = now.Add(d) }
+}
+
+// cached adds the lookup of word, found as entry, at the current generation.
+func cached(c *lookupCache, word string, entry Entry) {
+	c.add(c.generation(), word, Match{Entry: entry, Kind: MatchExact}, entry.Word != "")
+}
+
+func TestLookupCacheExpiry(t *testing.T) {
+	c, advance := newTestCache(CacheOptions{Size: 10, TTL: time.Minute, NegativeTTL: 10 * time.Second})
+
+	// 1. Found and missing words are served until their TTL expires.
+	cached(c, "chat", Entry{Word: "chat", Definition: "A cat."})
+	cached(c, "chien", Entry{})
+
+	match, found, ok := c.get("chat")
+	assert.True(t, ok)
+	assert.True(t, found)
+	assert.Equal(t, "A cat.", match.Entry.Definition)
+	_, found, ok = c.get("chien")
+	assert.True(t, ok)
+	assert.False(t, found)
+
+	advance(30 * time.Second)
+	_, _, ok = c.get("chien")
+	assert.False(t, ok, "misses expire after the negative TTL")
+	_, _, ok = c.get("chat")
+	assert.True(t, ok)
+
+	advance(time.Minute)
+	_, _, ok = c.get("chat")
+	assert.False(t, ok, "found words expire after the TTL")
+
+	// 2. Every get is counted.
+	stats := c.snapshot()
+	assert.Equal(t, uint64(3), stats.Hits)
+	assert.Equal(t, uint64(2), stats.Misses)
+	assert.Equal(t, 0, stats.Entries)
+}
+
+func TestLookupCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c, _ := newTestCache(CacheOptions{Size: 2, TTL: time.Minute})
+
+	// 1. Fill the cache, then use the oldest lookup.
+	cached(c, "one", Entry{Word: "one"})
+	cached(c, "two", Entry{Word: "two"})
+	c.get("one")
+
+	// 2. A third lookup evicts the least recently used one.
+	cached(c, "three", Entry{Word: "three"})
+	_, _, ok := c.get("two")
+	assert.False(t, ok)
+	_, _, ok = c.get("one")
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), c.snapshot().Evictions)
+	assert.Equal(t, 2, c.snapshot().Entries)
+
+	// 3. Without a negative TTL, misses are not cached.
+	cached(c, "four", Entry{})
+	_, _, ok = c.get("four")
+	assert.False(t, ok)
+}
+
+func TestLookupCacheInvalidation(t *testing.T) {
+	c, _ := newTestCache(CacheOptions{Size: 10, TTL: time.Minute, NegativeTTL: time.Minute})
+	id := primitive.NewObjectID()
+
+	// 1. Cache lookups that a change to "Running" could affect, and one it cannot.
+	cached(c, "Running", Entry{})
+	cached(c, "RUNNING", Entry{})
+	cached(c, "runs", Entry{})
+	cached(c, "jogging", Entry{ID: id, Word: "jog"})
+	cached(c, "chat", Entry{Word: "chat"})
+
+	// 2. Adding "Running" drops the lookups of the same word, key or lemma.
+	c.invalidate(Entry{Word: "Running", Key: "running", Lemma: "run"})
+	for _, word := range []string{"Running", "RUNNING", "runs"} {
+		_, _, ok := c.get(word)
+		assert.False(t, ok, word)
+	}
+	_, _, ok := c.get("chat")
+	assert.True(t, ok)
+
+	// 3. A deletion known only by its ID drops the lookups that matched the entry.
+	c.invalidate(Entry{ID: id})
+	_, _, ok = c.get("jogging")
+	assert.False(t, ok)
+	_, _, ok = c.get("chat")
+	assert.True(t, ok)
+
+	// 4. A lookup read before a change is not cached after it.
+	generation := c.generation()
+	c.invalidate(Entry{Word: "chien", Key: "chien", Lemma: "chien"})
+	c.add(generation, "chien", Match{}, false)
+	_, _, ok = c.get("chien")
+	assert.False(t, ok)
+
+	// 5. Purging drops everything.
+	c.purge()
+	assert.Equal(t, 0, c.snapshot().Entries)
+	assert.Equal(t, uint64(5), c.snapshot().Invalidations)
+}
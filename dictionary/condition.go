@@ -0,0 +1,101 @@
+package dictionary
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ETag returns the strong entity tag of the entry, which changes with every
+// write to it: the document ID followed by the version, e.g.
+// "65a1f0c2e4b0a1b2c3d4e5f6-3".
+func (e Entry) ETag() string {
+	return `"` + e.ID.Hex() + "-" + strconv.FormatInt(e.Version, 10) + `"`
+}
+
+// ListVersion identifies a state of the word list by its number of entries and
+// the time of its latest change. Adding or changing an entry moves UpdatedAt
+// forward, and removing one changes Count. Since a removal may also move
+// UpdatedAt back, only the ETag reliably tells removals apart.
+type ListVersion struct {
+	Count     int64
+	UpdatedAt time.Time
+}
+
+// ETag returns the strong entity tag of the word list in version v, e.g.
+// "list-42-1729339200123".
+func (v ListVersion) ETag() string {
+	return `"list-` + strconv.FormatInt(v.Count, 10) + "-" + strconv.FormatInt(v.UpdatedAt.UnixMilli(), 10) + `"`
+}
+
+// Condition restricts a change to entries in a known state, for optimistic
+// concurrency. The zero Condition allows any change.
+type Condition struct {
+	// IfMatch lists the entity tags of the states the change applies to, as
+	// returned by Entry.ETag; "*" matches any existing entry. When set, a change
+	// to an entry in another state, or to a missing entry, fails with
+	// ErrPreconditionFailed. Weak tags never match.
+	IfMatch []string
+}
+
+// IsZero reports whether c allows any change.
+func (c Condition) IsZero() bool {
+	return len(c.IfMatch) == 0
+}
+
+// filter returns the filter selecting the entries of word that satisfy c.
+func (c Condition) filter(word string) bson.M {
+	filter := bson.M{"word": word}
+	if c.IsZero() {
+		return filter
+	}
+
+	states := bson.A{}
+	for _, tag := range c.IfMatch {
+		if tag == "*" {
+			return filter
+		}
+		if id, version, ok := parseETag(tag); ok {
+			states = append(states, bson.M{"_id": id, "version": versionFilter(version)})
+		}
+	}
+	if len(states) == 0 {
+		// No tag can match; select nothing rather than everything.
+		states = append(states, bson.M{"_id": bson.M{"$exists": false}})
+	}
+	filter["$or"] = states
+	return filter
+}
+
+// parseETag splits a strong entity tag returned by Entry.ETag. The tag may carry
+// a suffix naming a representation of the entry, e.g. "...-3-csv".
+func parseETag(tag string) (primitive.ObjectID, int64, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return primitive.ObjectID{}, 0, false
+	}
+	parts := strings.SplitN(tag[1:len(tag)-1], "-", 3)
+	if len(parts) < 2 {
+		return primitive.ObjectID{}, 0, false
+	}
+	id, err := primitive.ObjectIDFromHex(parts[0])
+	if err != nil {
+		return primitive.ObjectID{}, 0, false
+	}
+	v, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || v < 0 {
+		return primitive.ObjectID{}, 0, false
+	}
+	return id, v, true
+}
+
+// versionFilter matches a version. Entries written before versions were
+// introduced have none, and report version 0 until their next change.
+func versionFilter(version int64) interface{} {
+	if version == 0 {
+		return bson.M{"$exists": false}
+	}
+	return version
+}
@@ -0,0 +1,91 @@
+package dictionary
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// duplicateWordsShown is the number of duplicate words named by ErrDuplicateWords.
+const duplicateWordsShown = 10
+
+// duplicatesPipeline lists the IDs of the entries of each word stored more than
+// once, oldest first.
+var duplicatesPipeline = mongo.Pipeline{
+	{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	{{Key: "$group", Value: bson.D{{Key: "_id", Value: "$word"}, {Key: "ids", Value: bson.D{{Key: "$push", Value: "$_id"}}}}}},
+	{{Key: "$match", Value: bson.D{{Key: "ids.1", Value: bson.D{{Key: "$exists", Value: true}}}}}},
+}
+
+// duplicate is a word stored more than once, with the IDs of its entries.
+type duplicate struct {
+	Word string        `bson:"_id"`
+	IDs  []interface{} `bson:"ids"`
+}
+
+// duplicateWords returns up to limit words stored more than once.
+func duplicateWords(ctx context.Context, collection *mongo.Collection, limit int64) ([]string, error) {
+	pipeline := append(mongo.Pipeline{}, duplicatesPipeline...)
+	pipeline = append(pipeline, bson.D{{Key: "$limit", Value: limit}})
+	cursor, err := collection.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var words []string
+	for cursor.Next(ctx) {
+		var dup duplicate
+		if err := cursor.Decode(&dup); err != nil {
+			return nil, err
+		}
+		words = append(words, fmt.Sprintf("%q (%d entries)", dup.Word, len(dup.IDs)))
+	}
+	return words, cursor.Err()
+}
+
+// Dedupe connects to the collection like NewDictionaryWithOptions, removes the
+// entries of words stored more than once by older versions, and creates the
+// indexes that NewDictionaryWithOptions refused to create over them. It keeps
+// the oldest entry of each word, the one lookups found first. It returns the
+// number of entries removed.
+func Dedupe(ctx context.Context, databaseURI, databaseName, collectionName string, opts Options) (int64, error) {
+	connectCtx, cancel := withTimeout(ctx, opts.Timeouts.Connect)
+	defer cancel()
+	collection, err := connect(connectCtx, databaseURI, databaseName, collectionName)
+	if err != nil {
+		return 0, err
+	}
+	defer collection.Database().Client().Disconnect(context.Background())
+
+	cursor, err := collection.Aggregate(ctx, duplicatesPipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return 0, fmt.Errorf("error deduplicating words: %w", classify(err))
+	}
+	defer cursor.Close(ctx)
+
+	var removed int64
+	for cursor.Next(ctx) {
+		var dup duplicate
+		if err := cursor.Decode(&dup); err != nil {
+			return removed, fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+		}
+		result, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": dup.IDs[1:]}})
+		if err != nil {
+			return removed, fmt.Errorf("error deduplicating words: %w", classify(err))
+		}
+		removed += result.DeletedCount
+	}
+	if err := cursor.Err(); err != nil {
+		return removed, fmt.Errorf("error deduplicating words: %w", classify(err))
+	}
+
+	// Create the indexes now, so that no duplicate can be stored again.
+	if err := createIndexes(ctx, collection); err != nil {
+		return removed, fmt.Errorf("error indexing words: %w", classify(err))
+	}
+	return removed, nil
+}
@@ -2,15 +2,30 @@ package dictionary
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // Entry represents a dictionary entry containing a definition.
 type Entry struct {
-	Definition string
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Word       string             `bson:"word" json:"word"`
+	Definition string             `bson:"definition" json:"definition"`
+	// Key is the normalized form of Word used for case- and accent-insensitive lookups.
+	Key string `bson:"key,omitempty" json:"key,omitempty"`
+	// Lemma is the lemma of Key used as a last lookup fallback.
+	Lemma     string    `bson:"lemma,omitempty" json:"lemma,omitempty"`
+	CreatedAt time.Time `bson:"created_at,omitempty" json:"created_at,omitempty"`
+	UpdatedAt time.Time `bson:"updated_at,omitempty" json:"updated_at,omitempty"`
+	// Version counts the writes to the entry, starting at 1; see ETag.
+	Version int64 `bson:"version,omitempty" json:"version"`
 }
 
 func (e Entry) String() string {
@@ -20,6 +35,70 @@ func (e Entry) String() string {
 // Dictionary represents a MongoDB-backed dictionary.
 type Dictionary struct {
 	collection *mongo.Collection
+	options    Options
+	// cache holds recent lookups; nil when disabled.
+	cache *lookupCache
+	// closed is canceled by Close, stopping WatchChanges.
+	closed context.Context
+	close  context.CancelFunc
+}
+
+// Options configures a Dictionary.
+type Options struct {
+	// StripAccents removes diacritics from normalized keys, so "cafe" finds "café".
+	StripAccents bool
+	// Language is the language of the words; lemma fallbacks need one with a
+	// lemmatizer, see LemmaFor.
+	Language string
+	// Timeouts bounds how long each operation may take.
+	Timeouts Timeouts
+	// Observer, when set, is called after each operation, e.g. to record metrics.
+	Observer Observer
+	// Cache configures the cache of lookups; the zero value disables it.
+	Cache CacheOptions
+}
+
+// Observer is told the name ("add", "create", "set", "update", "lookup", "remove",
+// "list", "count" or "list_version"), duration and error of each dictionary operation. Lookups of
+// missing words report ErrNotFound. Lookups served by the cache do not reach
+// MongoDB and are not reported.
+type Observer func(op string, duration time.Duration, err error)
+
+// Timeouts holds per-operation deadlines. A zero duration leaves the caller's context as is.
+type Timeouts struct {
+	Connect time.Duration
+	Add     time.Duration
+	Get     time.Duration
+	Remove  time.Duration
+	List    time.Duration
+}
+
+// DefaultTimeouts are the deadlines used by NewDictionary.
+var DefaultTimeouts = Timeouts{
+	Connect: 10 * time.Second,
+	Add:     5 * time.Second,
+	Get:     2 * time.Second,
+	Remove:  5 * time.Second,
+	List:    30 * time.Second,
+}
+
+// Match is the result of a lookup: the stored entry and the form that matched.
+type Match struct {
+	Entry Entry
+	Kind  MatchKind
+}
+
+// AddResult describes the outcome of Dictionary.Add.
+type AddResult struct {
+	Created int64 `json:"created"`
+	Entry   Entry `json:"entry"`
+}
+
+// RemoveResult describes the outcome of Dictionary.Remove.
+type RemoveResult struct {
+	Deleted   int64     `json:"deleted"`
+	Word      string    `json:"word"`
+	RemovedAt time.Time `json:"removed_at"`
 }
 
 // EntryOperation represents a dictionary operation for adding or updating an entry.
@@ -30,94 +109,511 @@ type EntryOperation struct {
 
 // NewDictionary creates a new instance of the Dictionary.
 func NewDictionary(databaseURI, databaseName, collectionName string) (*Dictionary, error) {
-	clientOptions := options.Client().ApplyURI(databaseURI)
+	return NewDictionaryWithOptions(databaseURI, databaseName, collectionName, Options{Timeouts: DefaultTimeouts})
+}
+
+// NewDictionaryWithOptions creates a new instance of the Dictionary with the given options.
+// It returns ErrDuplicateWords when the collection holds words stored more than
+// once by older versions; Dedupe removes them.
+func NewDictionaryWithOptions(databaseURI, databaseName, collectionName string, opts Options) (*Dictionary, error) {
+	ctx, cancel := withTimeout(context.Background(), opts.Timeouts.Connect)
+	defer cancel()
 
-	client, err := mongo.Connect(context.Background(), clientOptions)
+	collection, err := connect(ctx, databaseURI, databaseName, collectionName)
 	if err != nil {
 		return nil, err
 	}
 
-	err = client.Ping(context.Background(), nil)
+	err = createIndexes(ctx, collection)
+	if mongo.IsDuplicateKeyError(err) {
+		words, err := duplicateWords(ctx, collection, duplicateWordsShown)
+		if err != nil {
+			return nil, fmt.Errorf("error listing duplicate words: %w", classify(err))
+		}
+		return nil, fmt.Errorf("error indexing words: %w: %s", ErrDuplicateWords, strings.Join(words, ", "))
+	}
 	if err != nil {
-		return nil, err
+		return nil, classify(err)
 	}
 
-	return &Dictionary{
-		collection: client.Database(databaseName).Collection(collectionName),
-	}, nil
+	d := &Dictionary{
+		collection: collection,
+		options:    opts,
+	}
+	if opts.Cache.Size > 0 {
+		d.cache = newLookupCache(opts.Cache, opts.StripAccents, opts.Language)
+	}
+	d.closed, d.close = context.WithCancel(context.Background())
+	return d, nil
 }
 
-// Add adds a word with its definition to the dictionary.
-func (d *Dictionary) Add(word string, definition string) (string, error) {
-	entry := Entry{Definition: definition}
+// connect connects to MongoDB and returns the collection of the dictionary.
+func connect(ctx context.Context, databaseURI, databaseName, collectionName string) (*mongo.Collection, error) {
+	clientOptions := options.Client().ApplyURI(databaseURI)
+
+	client, err := mongo.Connect(ctx, clientOptions)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	err = client.Ping(ctx, nil)
+	if err != nil {
+		return nil, classify(err)
+	}
+
+	return client.Database(databaseName).Collection(collectionName), nil
+}
 
-	_, err := d.collection.InsertOne(context.TODO(), map[string]interface{}{
-		"word":       word,
-		"definition": entry.Definition,
+// createIndexes keeps words unique, so that concurrent creations cannot store a
+// word twice, and indexes the normalized forms used by lookup fallbacks.
+func createIndexes(ctx context.Context, collection *mongo.Collection) error {
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "word", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "key", Value: 1}}},
+		{Keys: bson.D{{Key: "lemma", Value: 1}}},
 	})
+	return err
+}
+
+// Database returns the MongoDB database holding the dictionary, so that related
+// collections such as API keys can share its connection.
+func (d *Dictionary) Database() *mongo.Database {
+	return d.collection.Database()
+}
+
+// Ping checks that MongoDB is reachable, within the Get timeout.
+func (d *Dictionary) Ping(ctx context.Context) error {
+	ctx, cancel := withTimeout(ctx, d.options.Timeouts.Get)
+	defer cancel()
+
+	if err := d.collection.Database().Client().Ping(ctx, nil); err != nil {
+		return fmt.Errorf("error pinging database: %w", classify(err))
+	}
+	return nil
+}
+
+// Close disconnects from MongoDB, waiting for in-progress operations until ctx is done.
+// Clients sharing the connection through Database cannot be used afterwards.
+func (d *Dictionary) Close(ctx context.Context) error {
+	d.close()
+	if err := d.collection.Database().Client().Disconnect(ctx); err != nil {
+		return fmt.Errorf("error disconnecting from database: %w", classify(err))
+	}
+	return nil
+}
 
+// Add adds a word with its definition to the dictionary and returns the stored entry.
+// It returns ErrExists when the word is already in the dictionary.
+func (d *Dictionary) Add(word string, definition string) (AddResult, error) {
+	return d.AddContext(context.Background(), word, definition)
+}
+
+// AddContext is like Add but honors the cancellation and deadline of ctx.
+func (d *Dictionary) AddContext(ctx context.Context, word string, definition string) (_ AddResult, err error) {
+	defer d.observe("add", time.Now(), &err)
+	defer d.forget(word)
+	ctx, cancel := withTimeout(ctx, d.options.Timeouts.Add)
+	defer cancel()
+
+	entry := d.newEntry(word, definition)
+	_, err = d.collection.InsertOne(ctx, entry)
+
+	if mongo.IsDuplicateKeyError(err) {
+		return AddResult{}, fmt.Errorf("%w: %s", ErrExists, word)
+	}
+	if err != nil {
+		return AddResult{}, fmt.Errorf("error adding word: %w", classify(err))
+	}
+
+	return AddResult{Created: 1, Entry: entry}, nil
+}
+
+// Create adds a word with its definition and returns ErrExists when the word is
+// already in the dictionary, like Add. Add inserts the entry and relies on the
+// unique index of words to reject it; Create upserts it with $setOnInsert, so an
+// existing entry is matched and left unchanged instead of failing the write.
+func (d *Dictionary) Create(word string, definition string) (AddResult, error) {
+	return d.CreateContext(context.Background(), word, definition)
+}
+
+// CreateContext is like Create but honors the cancellation and deadline of ctx.
+func (d *Dictionary) CreateContext(ctx context.Context, word string, definition string) (_ AddResult, err error) {
+	defer d.observe("create", time.Now(), &err)
+	defer d.forget(word)
+	ctx, cancel := withTimeout(ctx, d.options.Timeouts.Add)
+	defer cancel()
+
+	entry := d.newEntry(word, definition)
+
+	// Insert the entry only if no document has the word. Two concurrent upserts
+	// may both miss the word; the unique index then rejects the second insert.
+	result, err := d.collection.UpdateOne(ctx, bson.M{"word": word},
+		bson.M{"$setOnInsert": entry}, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return AddResult{}, fmt.Errorf("%w: %s", ErrExists, word)
+	}
+	if err != nil {
+		return AddResult{}, fmt.Errorf("error creating word: %w", classify(err))
+	}
+	if result.UpsertedCount == 0 {
+		return AddResult{}, fmt.Errorf("%w: %s", ErrExists, word)
+	}
+
+	return AddResult{Created: 1, Entry: entry}, nil
+}
+
+// Set replaces the definition of a word, adding the word when it is not in the
+// dictionary. It reports whether the word was created.
+func (d *Dictionary) Set(word string, definition string) (Entry, bool, error) {
+	return d.SetContext(context.Background(), word, definition)
+}
+
+// SetContext is like Set but honors the cancellation and deadline of ctx.
+func (d *Dictionary) SetContext(ctx context.Context, word string, definition string) (Entry, bool, error) {
+	return d.SetIfContext(ctx, word, definition, Condition{})
+}
+
+// SetIfContext is like SetContext but only replaces an entry satisfying cond.
+// A word is only created under the zero Condition.
+func (d *Dictionary) SetIfContext(ctx context.Context, word string, definition string, cond Condition) (_ Entry, created bool, err error) {
+	defer d.observe("set", time.Now(), &err)
+	defer d.forget(word)
+	ctx, cancel := withTimeout(ctx, d.options.Timeouts.Add)
+	defer cancel()
+
+	entry := d.newEntry(word, definition)
+	update := bson.M{
+		"$set":         bson.M{"definition": definition, "updated_at": entry.UpdatedAt},
+		"$inc":         bson.M{"version": 1},
+		"$setOnInsert": bson.M{"_id": entry.ID, "key": entry.Key, "lemma": entry.Lemma, "created_at": entry.CreatedAt},
+	}
+	result, err := d.collection.UpdateOne(ctx, cond.filter(word), update, options.Update().SetUpsert(cond.IsZero()))
+	if mongo.IsDuplicateKeyError(err) {
+		// A concurrent upsert inserted the word first; replace its definition instead.
+		result, err = d.collection.UpdateOne(ctx, cond.filter(word), update)
+	}
 	if err != nil {
-		return "", err
+		return Entry{}, false, fmt.Errorf("error setting word: %w", classify(err))
+	}
+	if result.UpsertedCount > 0 {
+		return entry, true, nil
+	}
+	if result.MatchedCount == 0 {
+		return Entry{}, false, d.unmatched(word, cond)
 	}
 
-	return fmt.Sprintf("Word '%s' Added successfully", word), nil
+	entry, err = d.findExact(ctx, word)
+	return entry, false, err
+}
+
+// Update replaces the definition of a word already in the dictionary.
+// It returns ErrNotFound when the word is not in the dictionary.
+func (d *Dictionary) Update(word string, definition string) (Entry, error) {
+	return d.UpdateContext(context.Background(), word, definition)
+}
+
+// UpdateContext is like Update but honors the cancellation and deadline of ctx.
+func (d *Dictionary) UpdateContext(ctx context.Context, word string, definition string) (Entry, error) {
+	return d.UpdateIfContext(ctx, word, definition, Condition{})
+}
+
+// UpdateIfContext is like UpdateContext but only updates an entry satisfying cond.
+func (d *Dictionary) UpdateIfContext(ctx context.Context, word string, definition string, cond Condition) (_ Entry, err error) {
+	defer d.observe("update", time.Now(), &err)
+	defer d.forget(word)
+	ctx, cancel := withTimeout(ctx, d.options.Timeouts.Add)
+	defer cancel()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	result, err := d.collection.UpdateMany(ctx, cond.filter(word), bson.M{
+		"$set": bson.M{"definition": definition, "updated_at": now},
+		"$inc": bson.M{"version": 1},
+	})
+	if err != nil {
+		return Entry{}, fmt.Errorf("error updating word: %w", classify(err))
+	}
+	if result.MatchedCount == 0 {
+		return Entry{}, d.unmatched(word, cond)
+	}
+
+	return d.findExact(ctx, word)
+}
+
+// newEntry builds a new entry for word, with its normalized forms and timestamps.
+func (d *Dictionary) newEntry(word, definition string) Entry {
+	key := NormalizeKey(word, d.options.StripAccents)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	return Entry{
+		ID:         primitive.NewObjectID(),
+		Word:       word,
+		Definition: definition,
+		Key:        key,
+		Lemma:      LemmaFor(d.options.Language, key),
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		Version:    1,
+	}
+}
+
+// unmatched returns the error of a change to word that matched no entry.
+func (d *Dictionary) unmatched(word string, cond Condition) error {
+	if cond.IsZero() {
+		return fmt.Errorf("%w: %s", ErrNotFound, word)
+	}
+	return fmt.Errorf("%w: %s", ErrPreconditionFailed, word)
+}
+
+// findExact reads the stored entry of word, without lookup fallbacks.
+func (d *Dictionary) findExact(ctx context.Context, word string) (Entry, error) {
+	var entry Entry
+	err := d.collection.FindOne(ctx, bson.M{"word": word}).Decode(&entry)
+	if err == mongo.ErrNoDocuments {
+		return Entry{}, fmt.Errorf("%w: %s", ErrNotFound, word)
+	}
+	if err != nil {
+		return Entry{}, fmt.Errorf("error getting word: %w", classify(err))
+	}
+	return entry, nil
 }
 
 // Get retrieves the definition of a word from the dictionary.
 func (d *Dictionary) Get(word string) (Entry, error) {
-	var result map[string]interface{}
-	err := d.collection.FindOne(context.TODO(), map[string]interface{}{
-		"word": word,
-	}).Decode(&result)
+	return d.GetContext(context.Background(), word)
+}
 
+// GetContext is like Get but honors the cancellation and deadline of ctx.
+func (d *Dictionary) GetContext(ctx context.Context, word string) (Entry, error) {
+	match, err := d.LookupContext(ctx, word)
 	if err != nil {
-		return Entry{}, fmt.Errorf("word not found: %s", word)
+		return Entry{}, err
+	}
+
+	return match.Entry, nil
+}
+
+// Lookup finds a word, falling back from the exact word to its normalized key
+// and then to its lemma. The returned Match tells which form matched.
+func (d *Dictionary) Lookup(word string) (Match, error) {
+	return d.LookupContext(context.Background(), word)
+}
+
+// LookupContext is like Lookup but honors the cancellation and deadline of ctx.
+// The Get timeout bounds the whole lookup, fallbacks included. Recent lookups,
+// found or missing, are served from the cache when it is enabled.
+func (d *Dictionary) LookupContext(ctx context.Context, word string) (Match, error) {
+	if d.cache == nil {
+		return d.lookup(ctx, word)
 	}
 
-	definition, ok := result["definition"].(string)
-	if !ok {
-		return Entry{}, fmt.Errorf("invalid data structure for definition: %v", result["definition"])
+	if match, found, ok := d.cache.get(word); ok {
+		if !found {
+			return Match{}, fmt.Errorf("%w: %s", ErrNotFound, word)
+		}
+		return match, nil
 	}
 
-	return Entry{Definition: definition}, nil
+	generation := d.cache.generation()
+	match, err := d.lookup(ctx, word)
+	if err == nil || errors.Is(err, ErrNotFound) {
+		d.cache.add(generation, word, match, err == nil)
+	}
+	return match, err
 }
 
-// Remove removes a word and its definition from the dictionary.
-func (d *Dictionary) Remove(word string) (string, error) {
-	filter := map[string]interface{}{
-		"word": word,
+// lookup finds a word in MongoDB, bypassing the cache.
+func (d *Dictionary) lookup(ctx context.Context, word string) (_ Match, err error) {
+	defer d.observe("lookup", time.Now(), &err)
+	ctx, cancel := withTimeout(ctx, d.options.Timeouts.Get)
+	defer cancel()
+
+	key := NormalizeKey(word, d.options.StripAccents)
+
+	type candidate struct {
+		kind   MatchKind
+		filter bson.M
+	}
+	candidates := []candidate{
+		{MatchExact, bson.M{"word": word}},
+		{MatchNormalized, bson.M{"key": key}},
+	}
+	if lemma := LemmaFor(d.options.Language, key); lemma != "" {
+		candidates = append(candidates, candidate{MatchLemma, bson.M{"lemma": lemma}})
+	}
+
+	for _, c := range candidates {
+		var entry Entry
+		err := d.collection.FindOne(ctx, c.filter).Decode(&entry)
+		if err == mongo.ErrNoDocuments {
+			continue
+		}
+		if err != nil {
+			return Match{}, fmt.Errorf("error getting word: %w", classify(err))
+		}
+
+		return Match{Entry: entry, Kind: c.kind}, nil
 	}
 
-	result, err := d.collection.DeleteMany(context.TODO(), filter)
+	return Match{}, fmt.Errorf("%w: %s", ErrNotFound, word)
+}
+
+// Remove removes a word and its definition from the dictionary.
+// It returns ErrNotFound when the word is not in the dictionary.
+func (d *Dictionary) Remove(word string) (RemoveResult, error) {
+	return d.RemoveContext(context.Background(), word)
+}
+
+// RemoveContext is like Remove but honors the cancellation and deadline of ctx.
+func (d *Dictionary) RemoveContext(ctx context.Context, word string) (RemoveResult, error) {
+	return d.RemoveIfContext(ctx, word, Condition{})
+}
+
+// RemoveIfContext is like RemoveContext but only removes an entry satisfying cond.
+func (d *Dictionary) RemoveIfContext(ctx context.Context, word string, cond Condition) (_ RemoveResult, err error) {
+	defer d.observe("remove", time.Now(), &err)
+	defer d.forget(word)
+	ctx, cancel := withTimeout(ctx, d.options.Timeouts.Remove)
+	defer cancel()
+
+	result, err := d.collection.DeleteMany(ctx, cond.filter(word))
 	if err != nil {
-		return "", fmt.Errorf("error removing word: %v", err)
+		return RemoveResult{}, fmt.Errorf("error removing word: %w", classify(err))
 	}
 
 	if result.DeletedCount == 0 {
-		return fmt.Sprintf("Word '%s' not found", word), nil
+		return RemoveResult{}, d.unmatched(word, cond)
 	}
 
-	return fmt.Sprintf("Word '%s' removed successfully", word), nil
+	return RemoveResult{
+		Deleted:   result.DeletedCount,
+		Word:      word,
+		RemovedAt: time.Now().UTC(),
+	}, nil
 }
 
 // List retrieves a list of all words in the dictionary.
 func (d *Dictionary) List() ([]string, error) {
-	cursor, err := d.collection.Find(context.TODO(), map[string]interface{}{})
+	return d.ListContext(context.Background())
+}
+
+// ListContext is like List but honors the cancellation and deadline of ctx.
+func (d *Dictionary) ListContext(ctx context.Context) (_ []string, err error) {
+	defer d.observe("list", time.Now(), &err)
+	ctx, cancel := withTimeout(ctx, d.options.Timeouts.List)
+	defer cancel()
+
+	cursor, err := d.collection.Find(ctx, map[string]interface{}{})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error listing words: %w", classify(err))
 	}
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
 	var words []string
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 		var result map[string]interface{}
 		if err := cursor.Decode(&result); err != nil {
 			return nil, err
 		}
 
 		// Exclude _id field and extract word
-		words = append(words, result["word"].(string))
+		word, ok := result["word"].(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: word is %v", ErrInvalidEntry, result["word"])
+		}
+		words = append(words, word)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("error listing words: %w", classify(err))
 	}
 
 	return words, nil
 }
+
+// Count returns an estimate of the number of entries in the dictionary.
+func (d *Dictionary) Count() (int64, error) {
+	return d.CountContext(context.Background())
+}
+
+// CountContext is like Count but honors the cancellation and deadline of ctx.
+// It is bounded by the Get timeout.
+func (d *Dictionary) CountContext(ctx context.Context) (_ int64, err error) {
+	defer d.observe("count", time.Now(), &err)
+	ctx, cancel := withTimeout(ctx, d.options.Timeouts.Get)
+	defer cancel()
+
+	count, err := d.collection.EstimatedDocumentCount(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error counting words: %w", classify(err))
+	}
+	return count, nil
+}
+
+// ListVersion returns the current version of the word list.
+func (d *Dictionary) ListVersion() (ListVersion, error) {
+	return d.ListVersionContext(context.Background())
+}
+
+// ListVersionContext is like ListVersion but honors the cancellation and deadline of ctx.
+// Entries written before updated_at was recorded count with their creation time.
+func (d *Dictionary) ListVersionContext(ctx context.Context) (_ ListVersion, err error) {
+	defer d.observe("list_version", time.Now(), &err)
+	ctx, cancel := withTimeout(ctx, d.options.Timeouts.List)
+	defer cancel()
+
+	cursor, err := d.collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":        nil,
+			"count":      bson.M{"$sum": 1},
+			"updated_at": bson.M{"$max": bson.M{"$ifNull": bson.A{"$updated_at", "$created_at"}}},
+		}}},
+	})
+	if err != nil {
+		return ListVersion{}, fmt.Errorf("error versioning words: %w", classify(err))
+	}
+	defer cursor.Close(ctx)
+
+	var version struct {
+		Count     int64     `bson:"count"`
+		UpdatedAt time.Time `bson:"updated_at"`
+	}
+	if cursor.Next(ctx) {
+		if err := cursor.Decode(&version); err != nil {
+			return ListVersion{}, err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return ListVersion{}, fmt.Errorf("error versioning words: %w", classify(err))
+	}
+
+	return ListVersion{Count: version.Count, UpdatedAt: version.UpdatedAt.UTC()}, nil
+}
+
+// CacheStats returns the statistics of the lookup cache; they are zero when it is disabled.
+func (d *Dictionary) CacheStats() CacheStats {
+	if d.cache == nil {
+		return CacheStats{}
+	}
+	return d.cache.snapshot()
+}
+
+// forget invalidates the cached lookups that a change to word could affect.
+func (d *Dictionary) forget(word string) {
+	if d.cache != nil {
+		key := NormalizeKey(word, d.options.StripAccents)
+		d.cache.invalidate(Entry{Word: word, Key: key, Lemma: LemmaFor(d.options.Language, key)})
+	}
+}
+
+// observe reports an operation started at start to the observer, if any.
+func (d *Dictionary) observe(op string, start time.Time, err *error) {
+	if d.options.Observer != nil {
+		d.options.Observer(op, time.Since(start), *err)
+	}
+}
+
+// withTimeout derives a context bounded by timeout, or returns ctx unchanged when timeout is zero.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
@@ -2,26 +2,36 @@
 package dictionary_test
 
 import (
+	"context"
 	"estiam/dictionary"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func TestAddWord(t *testing.T) {
 	// Step 1: Create a new instance of the Dictionary.
 	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "testCollection")
 	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+	d.Remove("testWord")
 
 	// Step 2: Call the Add function to add a word to the dictionary.
 	word := "testWord"
 	definition := "testDefinition"
-	message, err := d.Add(word, definition)
+	result, err := d.Add(word, definition)
 
 	// Step 3: Use assertions to verify that the word was added successfully.
 	assert.NoError(t, err, "Unexpected error adding word")
-	assert.Equal(t, fmt.Sprintf("Word '%s' Added successfully", word), message, "Unexpected message")
+	assert.Equal(t, int64(1), result.Created, "Unexpected created count")
+	assert.Equal(t, word, result.Entry.Word, "Unexpected stored word")
+	assert.Equal(t, definition, result.Entry.Definition, "Unexpected stored definition")
+	assert.False(t, result.Entry.CreatedAt.IsZero(), "Expected a creation timestamp")
 
 	// Check that the word is actually in the dictionary
 	entry, err := d.Get(word)
@@ -33,6 +43,7 @@ func TestGetWord(t *testing.T) {
 	// Step 1: Create a new instance of the Dictionary.
 	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "testCollection")
 	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+	d.Remove("testWord")
 
 	// Step 2: Add a word to the dictionary.
 	word := "testWord"
@@ -52,6 +63,7 @@ func TestRemoveWord(t *testing.T) {
 	// Step 1: Create a new instance of the Dictionary.
 	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "testCollection")
 	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+	d.Remove("testWord")
 
 	// Step 2: Add a word to the dictionary.
 	word := "testWord"
@@ -60,15 +72,162 @@ func TestRemoveWord(t *testing.T) {
 	assert.NoError(t, err, "Unexpected error adding word")
 
 	// Step 3: Call the Remove function to remove the added word.
-	message, err := d.Remove(word)
+	result, err := d.Remove(word)
 
 	// Step 4: Use assertions to verify that the word was removed successfully.
 	assert.NoError(t, err, "Unexpected error removing word")
-	assert.Equal(t, fmt.Sprintf("Word '%s' removed successfully", word), message, "Unexpected message")
+	assert.Equal(t, word, result.Word, "Unexpected removed word")
+	assert.GreaterOrEqual(t, result.Deleted, int64(1), "Unexpected deleted count")
 
 	// Check that the word is no longer in the dictionary
 	_, err = d.Get(word)
-	assert.Error(t, err, "Expected error getting removed word")
+	assert.ErrorIs(t, err, dictionary.ErrNotFound, "Expected error getting removed word")
+
+	// Removing it again reports that the word was not found.
+	_, err = d.Remove(word)
+	assert.ErrorIs(t, err, dictionary.ErrNotFound, "Expected error removing missing word")
+}
+
+func TestCreateWord(t *testing.T) {
+	// Step 1: Create a new instance of the Dictionary, without the word.
+	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "testCollection")
+	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+	d.Remove("createdWord")
+
+	// Step 2: Create the word.
+	result, err := d.Create("createdWord", "createdDefinition")
+	assert.NoError(t, err, "Unexpected error creating word")
+	assert.Equal(t, int64(1), result.Created, "Unexpected created count")
+
+	// Step 3: Creating it again reports that the word exists.
+	_, err = d.Create("createdWord", "otherDefinition")
+	assert.ErrorIs(t, err, dictionary.ErrExists, "Expected error creating existing word")
+
+	entry, err := d.Get("createdWord")
+	assert.NoError(t, err, "Unexpected error getting word")
+	assert.Equal(t, "createdDefinition", entry.Definition, "Definition should not change")
+}
+
+func TestCreateWordConcurrently(t *testing.T) {
+	// Step 1: Create a new instance of the Dictionary, without the word.
+	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "testCollection")
+	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+	d.Remove("concurrentWord")
+
+	// Step 2: Create the word from many goroutines at once.
+	const creators = 16
+	errs := make(chan error, creators)
+	var wg sync.WaitGroup
+	for i := 0; i < creators; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := d.Create("concurrentWord", fmt.Sprintf("definition %d", i))
+			errs <- err
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	// Step 3: Exactly one creation succeeds, the others report that the word exists.
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		assert.ErrorIs(t, err, dictionary.ErrExists)
+	}
+	assert.Equal(t, 1, created, "Expected a single creation")
+
+	// Step 4: The dictionary holds the word once.
+	words, err := d.List()
+	assert.NoError(t, err, "Unexpected error listing words")
+	count := 0
+	for _, word := range words {
+		if word == "concurrentWord" {
+			count++
+		}
+	}
+	assert.Equal(t, 1, count, "Expected the word to be stored once")
+}
+
+func TestSetAndUpdateWord(t *testing.T) {
+	// Step 1: Create a new instance of the Dictionary, without the word.
+	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "testCollection")
+	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+	d.Remove("setWord")
+
+	// Step 2: Updating a missing word reports that it was not found.
+	_, err = d.Update("setWord", "updatedDefinition")
+	assert.ErrorIs(t, err, dictionary.ErrNotFound, "Expected error updating missing word")
+
+	// Step 3: Set creates the word, then replaces its definition.
+	entry, created, err := d.Set("setWord", "firstDefinition")
+	assert.NoError(t, err, "Unexpected error setting word")
+	assert.True(t, created, "Expected the word to be created")
+	assert.Equal(t, "firstDefinition", entry.Definition)
+
+	entry, created, err = d.Set("setWord", "secondDefinition")
+	assert.NoError(t, err, "Unexpected error setting word")
+	assert.False(t, created, "Expected the word to be replaced")
+	assert.Equal(t, "secondDefinition", entry.Definition)
+
+	// Step 4: Update replaces the definition of the existing word.
+	entry, err = d.Update("setWord", "updatedDefinition")
+	assert.NoError(t, err, "Unexpected error updating word")
+	assert.Equal(t, "updatedDefinition", entry.Definition)
+	assert.False(t, entry.UpdatedAt.Before(entry.CreatedAt), "Expected the update timestamp to move forward")
+}
+
+func TestETag(t *testing.T) {
+	id, err := primitive.ObjectIDFromHex("65a1f0c2e4b0a1b2c3d4e5f6")
+	assert.NoError(t, err)
+
+	// The tag changes with the version, and with the document when a word is re-created.
+	entry := dictionary.Entry{ID: id, Word: "chat", Version: 3}
+	assert.Equal(t, `"65a1f0c2e4b0a1b2c3d4e5f6-3"`, entry.ETag())
+	entry.Version++
+	assert.Equal(t, `"65a1f0c2e4b0a1b2c3d4e5f6-4"`, entry.ETag())
+	entry.ID = primitive.NewObjectID()
+	assert.NotEqual(t, `"65a1f0c2e4b0a1b2c3d4e5f6-4"`, entry.ETag())
+
+	// The list tag changes with the number of words and the latest change.
+	version := dictionary.ListVersion{Count: 42, UpdatedAt: time.UnixMilli(1729339200123)}
+	assert.Equal(t, `"list-42-1729339200123"`, version.ETag())
+	version.Count--
+	assert.Equal(t, `"list-41-1729339200123"`, version.ETag())
+	version.UpdatedAt = version.UpdatedAt.Add(time.Millisecond)
+	assert.Equal(t, `"list-41-1729339200124"`, version.ETag())
+}
+
+func TestConditionalChanges(t *testing.T) {
+	// Step 1: Create a new instance of the Dictionary holding the word.
+	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "testCollection")
+	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+	d.Remove("conditionalWord")
+	created, err := d.Create("conditionalWord", "firstDefinition")
+	assert.NoError(t, err, "Unexpected error creating word")
+	assert.Equal(t, int64(1), created.Entry.Version)
+
+	// Step 2: A change with the current ETag applies, and bumps the version.
+	current := dictionary.Condition{IfMatch: []string{created.Entry.ETag()}}
+	entry, err := d.UpdateIfContext(context.Background(), "conditionalWord", "secondDefinition", current)
+	assert.NoError(t, err, "Unexpected error updating word")
+	assert.Equal(t, int64(2), entry.Version)
+
+	// Step 3: Changes with the stale ETag fail, and leave the entry alone.
+	_, _, err = d.SetIfContext(context.Background(), "conditionalWord", "thirdDefinition", current)
+	assert.ErrorIs(t, err, dictionary.ErrPreconditionFailed)
+	_, err = d.RemoveIfContext(context.Background(), "conditionalWord", current)
+	assert.ErrorIs(t, err, dictionary.ErrPreconditionFailed)
+
+	// Step 4: "*" matches any existing entry, but no missing one.
+	anyEntry := dictionary.Condition{IfMatch: []string{"*"}}
+	_, err = d.RemoveIfContext(context.Background(), "conditionalWord", anyEntry)
+	assert.NoError(t, err, "Unexpected error removing word")
+	_, _, err = d.SetIfContext(context.Background(), "conditionalWord", "fourthDefinition", anyEntry)
+	assert.ErrorIs(t, err, dictionary.ErrPreconditionFailed)
 }
 
 func TestListWords(t *testing.T) {
@@ -87,6 +246,7 @@ func TestListWords(t *testing.T) {
 	}
 
 	for _, entry := range wordsToAdd {
+		d.Remove(entry.word)
 		_, err := d.Add(entry.word, entry.definition)
 		assert.NoError(t, err, "Unexpected error adding word")
 	}
@@ -99,3 +259,164 @@ func TestListWords(t *testing.T) {
 	expectedWords := []string{"word1", "word2", "word3"}
 	assert.ElementsMatch(t, expectedWords, words, "Unexpected list of words")
 }
+
+func TestLookupFallbacks(t *testing.T) {
+	// Step 1: Create a new instance of the English Dictionary that strips accents.
+	d, err := dictionary.NewDictionaryWithOptions("mongodb://localhost:27017", "testDB", "testCollection",
+		dictionary.Options{StripAccents: true, Language: "en"})
+	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+
+	// Step 2: Add words to the dictionary.
+	d.Remove("Café")
+	d.Remove("run")
+	_, err = d.Add("Café", "A small restaurant")
+	assert.NoError(t, err, "Unexpected error adding word")
+	_, err = d.Add("run", "To move swiftly on foot")
+	assert.NoError(t, err, "Unexpected error adding word")
+
+	// Step 3: Look words up through each fallback and verify which form matched.
+	match, err := d.Lookup("Café")
+	assert.NoError(t, err, "Unexpected error looking up word")
+	assert.Equal(t, dictionary.MatchExact, match.Kind)
+
+	match, err = d.Lookup("CAFE")
+	assert.NoError(t, err, "Unexpected error looking up word")
+	assert.Equal(t, dictionary.MatchNormalized, match.Kind)
+	assert.Equal(t, "Café", match.Entry.Word)
+
+	match, err = d.Lookup("running")
+	assert.NoError(t, err, "Unexpected error looking up word")
+	assert.Equal(t, dictionary.MatchLemma, match.Kind)
+	assert.Equal(t, "run", match.Entry.Word)
+}
+
+func TestReindexLegacyEntries(t *testing.T) {
+	// Step 1: Create a new instance of the English Dictionary, and store an entry
+	// as older versions did, without its normalized key and lemma.
+	d, err := dictionary.NewDictionaryWithOptions("mongodb://localhost:27017", "testDB", "testCollection",
+		dictionary.Options{Language: "en"})
+	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+	d.Remove("Walk")
+	_, err = d.Database().Collection("testCollection").InsertOne(context.Background(),
+		bson.M{"word": "Walk", "definition": "To move on foot"})
+	assert.NoError(t, err, "Unexpected error inserting legacy entry")
+
+	// Step 2: The exact word is found, but not its other forms.
+	match, err := d.Lookup("Walk")
+	assert.NoError(t, err, "Unexpected error looking up word")
+	assert.Equal(t, dictionary.MatchExact, match.Kind)
+	_, err = d.Lookup("walking")
+	assert.ErrorIs(t, err, dictionary.ErrNotFound)
+
+	// Step 3: Reindexing stores the missing forms, once.
+	updated, err := d.Reindex(context.Background())
+	assert.NoError(t, err, "Unexpected error reindexing")
+	assert.GreaterOrEqual(t, updated, int64(1))
+
+	match, err = d.Lookup("WALK")
+	assert.NoError(t, err, "Unexpected error looking up word")
+	assert.Equal(t, dictionary.MatchNormalized, match.Kind)
+	match, err = d.Lookup("walking")
+	assert.NoError(t, err, "Unexpected error looking up word")
+	assert.Equal(t, dictionary.MatchLemma, match.Kind)
+	assert.Equal(t, "Walk", match.Entry.Word)
+
+	updated, err = d.Reindex(context.Background())
+	assert.NoError(t, err, "Unexpected error reindexing")
+	assert.Zero(t, updated, "Expected nothing left to reindex")
+}
+
+func TestDedupeLegacyDuplicates(t *testing.T) {
+	// Step 1: Store a word twice, as older versions could before words were unique.
+	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "dedupeCollection")
+	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+	collection := d.Database().Collection("dedupeCollection")
+	assert.NoError(t, collection.Drop(context.Background()), "Unexpected error dropping collection")
+	_, err = collection.InsertMany(context.Background(), []interface{}{
+		bson.M{"_id": primitive.NewObjectID(), "word": "chat", "definition": "A cat"},
+		bson.M{"_id": primitive.NewObjectID(), "word": "chat", "definition": "An online conversation"},
+		bson.M{"_id": primitive.NewObjectID(), "word": "chien", "definition": "A dog"},
+	})
+	assert.NoError(t, err, "Unexpected error inserting legacy entries")
+	assert.NoError(t, d.Close(context.Background()))
+
+	// Step 2: The dictionary refuses to start, naming the duplicate word.
+	_, err = dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "dedupeCollection")
+	assert.ErrorIs(t, err, dictionary.ErrDuplicateWords)
+	assert.ErrorContains(t, err, `"chat" (2 entries)`)
+	assert.NotContains(t, err.Error(), "chien")
+
+	// Step 3: Dedupe keeps the oldest entry, after which the dictionary starts.
+	removed, err := dictionary.Dedupe(context.Background(), "mongodb://localhost:27017", "testDB", "dedupeCollection", dictionary.Options{})
+	assert.NoError(t, err, "Unexpected error deduplicating")
+	assert.Equal(t, int64(1), removed)
+
+	d, err = dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "dedupeCollection")
+	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+	entry, err := d.Get("chat")
+	assert.NoError(t, err, "Unexpected error getting word")
+	assert.Equal(t, "A cat", entry.Definition)
+}
+
+func TestNormalizeKey(t *testing.T) {
+	assert.Equal(t, "hello", dictionary.NormalizeKey("Hello", false))
+	assert.Equal(t, "strasse", dictionary.NormalizeKey("STRASSE", false))
+	assert.Equal(t, "café", dictionary.NormalizeKey("CAFÉ", false))
+	assert.Equal(t, "cafe", dictionary.NormalizeKey("CAFÉ", true))
+}
+
+func TestLemma(t *testing.T) {
+	// Inflected forms must share a lemma with their base form.
+	forms := map[string]string{
+		"running": "run",
+		"runs":    "run",
+		"ran":     "run",
+		"making":  "make",
+		"studies": "study",
+		"boxes":   "box",
+		"mice":    "mouse",
+	}
+	for form, base := range forms {
+		assert.Equal(t, dictionary.Lemma(base), dictionary.Lemma(form), form)
+	}
+
+	assert.Equal(t, "run", dictionary.Lemma("running"))
+	assert.Equal(t, "glass", dictionary.Lemma("glass"))
+	assert.Equal(t, "e.g.", dictionary.Lemma("e.g."))
+
+	// Doubled multi-byte letters are un-doubled whole, leaving valid UTF-8, even
+	// when their encoding ends in two equal bytes (U+1FBE is E1 BE BE).
+	lemma := dictionary.Lemma("aιιing")
+	assert.True(t, utf8.ValidString(lemma), lemma)
+	assert.Equal(t, "aι", lemma)
+}
+
+func TestLemmaFor(t *testing.T) {
+	// Only English words are lemmatized; others have no lemma to fall back to.
+	assert.Equal(t, "run", dictionary.LemmaFor("en", "running"))
+	assert.Empty(t, dictionary.LemmaFor("fr", "chantes"))
+	assert.Empty(t, dictionary.LemmaFor("", "running"))
+}
+
+func TestNewDictionaryUnavailable(t *testing.T) {
+	// Step 1: Connect to an address where no server listens, with a short deadline.
+	opts := dictionary.Options{Timeouts: dictionary.Timeouts{Connect: 200 * time.Millisecond}}
+	_, err := dictionary.NewDictionaryWithOptions("mongodb://localhost:1", "testDB", "testCollection", opts)
+
+	// Step 2: Verify that the error reports the database as unavailable.
+	assert.ErrorIs(t, err, dictionary.ErrUnavailable, "Expected the dictionary to be unavailable")
+}
+
+func TestGetContextCanceled(t *testing.T) {
+	// Step 1: Create a new instance of the Dictionary.
+	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "testCollection")
+	assert.NoError(t, err, "Unexpected error creating dictionary instance")
+
+	// Step 2: Look a word up with a context that is already canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = d.GetContext(ctx, "testWord")
+
+	// Step 3: Verify that the lookup stops with the context error.
+	assert.ErrorIs(t, err, context.Canceled, "Expected the lookup to be canceled")
+}
@@ -0,0 +1,43 @@
+package dictionary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// Sentinel errors returned by Dictionary methods. Callers should match them with errors.Is.
+var (
+	// ErrNotFound is returned when a word is not in the dictionary.
+	ErrNotFound = errors.New("word not found")
+	// ErrExists is returned when creating a word that is already in the dictionary.
+	ErrExists = errors.New("word already exists")
+	// ErrPreconditionFailed is returned when a conditional change finds the entry in another state.
+	ErrPreconditionFailed = errors.New("entry has changed")
+	// ErrInvalidEntry is returned when a stored document cannot be read as an entry.
+	ErrInvalidEntry = errors.New("invalid entry")
+	// ErrTimeout is returned when an operation exceeds its deadline.
+	ErrTimeout = errors.New("dictionary operation timed out")
+	// ErrDuplicateWords is returned when the collection holds words stored more than once.
+	ErrDuplicateWords = errors.New("duplicate words")
+	// ErrUnavailable is returned when the database cannot be reached.
+	ErrUnavailable = errors.New("dictionary unavailable")
+)
+
+// classify wraps a database error with ErrUnavailable or ErrTimeout when it comes
+// from an unreachable server or an exceeded deadline. Cancellations are left as is.
+func classify(err error) error {
+	var selectionErr topology.ServerSelectionError
+	switch {
+	case errors.Is(err, context.Canceled):
+		return err
+	case errors.As(err, &selectionErr), mongo.IsNetworkError(err):
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	case errors.Is(err, context.DeadlineExceeded), mongo.IsTimeout(err):
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package dictionary
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// MatchKind tells which form of a word matched a lookup.
+type MatchKind string
+
+const (
+	// MatchExact means the word was found exactly as requested.
+	MatchExact MatchKind = "exact"
+	// MatchNormalized means the case-folded (and possibly accent-stripped) form matched.
+	MatchNormalized MatchKind = "normalized"
+	// MatchLemma means the lemma of the word matched, e.g. "running" found "run".
+	MatchLemma MatchKind = "lemma"
+)
+
+// NormalizeKey returns the lookup key of a word: its NFC, Unicode case-folded form,
+// with accents removed when stripAccents is true.
+func NormalizeKey(word string, stripAccents bool) string {
+	key := cases.Fold().String(norm.NFC.String(strings.TrimSpace(word)))
+	if !stripAccents {
+		return key
+	}
+
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	stripped, _, err := transform.String(t, key)
+	if err != nil {
+		return key
+	}
+	return stripped
+}
+
+// irregularLemmas maps common irregular English forms to their lemma.
+var irregularLemmas = map[string]string{
+	"am": "be", "is": "be", "are": "be", "was": "be", "were": "be", "been": "be",
+	"has": "have", "had": "have", "did": "do", "does": "do", "done": "do",
+	"went": "go", "gone": "go", "ran": "run", "ate": "eat", "eaten": "eat",
+	"saw": "see", "seen": "see", "took": "take", "taken": "take",
+	"gave": "give", "given": "give", "made": "make", "said": "say",
+	"better": "good", "best": "good", "worse": "bad", "worst": "bad",
+	"children": "child", "men": "man", "women": "woman", "mice": "mouse",
+	"feet": "foot", "teeth": "tooth", "geese": "goose", "people": "person",
+}
+
+// LemmaFor returns the lemma of a normalized key in the given language, or ""
+// when the language has no lemmatizer. Only English ("en") has one, Lemma.
+func LemmaFor(language, key string) string {
+	if language != "en" {
+		return ""
+	}
+	return Lemma(key)
+}
+
+// Lemma reduces a normalized key to an approximate English lemma, e.g. "running" to "run".
+// Irregular forms are looked up in a small table; other words go through a light
+// suffix-stripping stemmer. Keys containing anything but letters are returned as-is.
+func Lemma(key string) string {
+	if lemma, ok := irregularLemmas[key]; ok {
+		return Lemma(lemma)
+	}
+	for _, r := range key {
+		if !unicode.IsLetter(r) {
+			return key
+		}
+	}
+	if utf8.RuneCountInString(key) <= 3 {
+		return key
+	}
+
+	// The suffixes are ASCII, so trimming them leaves whole runes.
+	stem := key
+	switch {
+	case strings.HasSuffix(key, "ies"), strings.HasSuffix(key, "ied"):
+		stem = key[:len(key)-len("ies")] + "y"
+	case strings.HasSuffix(key, "sses"):
+		stem = strings.TrimSuffix(key, "es")
+	case strings.HasSuffix(key, "ss"):
+		stem = key
+	case strings.HasSuffix(key, "ing"):
+		stem = stripSuffix(key, "ing")
+	case strings.HasSuffix(key, "ed"):
+		stem = stripSuffix(key, "ed")
+	case strings.HasSuffix(key, "xes"), strings.HasSuffix(key, "ches"), strings.HasSuffix(key, "shes"), strings.HasSuffix(key, "zes"):
+		stem = strings.TrimSuffix(key, "es")
+	case strings.HasSuffix(key, "s") && !strings.HasSuffix(key, "us"):
+		stem = strings.TrimSuffix(key, "s")
+	}
+
+	// Strip a final silent "e" so that "make" and "making" share a lemma.
+	if strings.HasSuffix(stem, "e") && utf8.RuneCountInString(stem) > 3 {
+		stem = strings.TrimSuffix(stem, "e")
+	}
+	return stem
+}
+
+// stripSuffix removes an inflectional suffix when the remaining stem still holds a
+// vowel and is long enough, and un-doubles a final consonant ("runn" to "run").
+func stripSuffix(word, suffix string) string {
+	stem := strings.TrimSuffix(word, suffix)
+	if utf8.RuneCountInString(stem) < 3 || !strings.ContainsAny(stem, "aeiouy") {
+		return word
+	}
+
+	last, size := utf8.DecodeLastRuneInString(stem)
+	previous, _ := utf8.DecodeLastRuneInString(stem[:len(stem)-size])
+	if last == previous && !strings.ContainsRune("aeiouslz", last) {
+		stem = stem[:len(stem)-size]
+	}
+	return stem
+}
@@ -0,0 +1,80 @@
+package dictionary
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reindexBatchSize is the number of entries updated per bulk write by Reindex.
+const reindexBatchSize = 500
+
+// Reindex recomputes the normalized key and lemma stored with each entry, updating
+// the entries whose stored forms differ: entries stored before lookups fell back
+// to them, or after StripAccents or Language changed. Lookup fallbacks miss those
+// entries until they are reindexed. It returns the number of entries updated.
+func (d *Dictionary) Reindex(ctx context.Context) (int64, error) {
+	cursor, err := d.collection.Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"word": 1, "key": 1, "lemma": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("error reindexing words: %w", classify(err))
+	}
+	defer cursor.Close(ctx)
+
+	var updated int64
+	var batch []mongo.WriteModel
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		result, err := d.collection.BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("error reindexing words: %w", classify(err))
+		}
+		updated += result.ModifiedCount
+		batch = batch[:0]
+		return nil
+	}
+	// Purge even after a failure, since some entries may have been updated.
+	defer func() {
+		if d.cache != nil && updated > 0 {
+			d.cache.purge()
+		}
+	}()
+
+	for cursor.Next(ctx) {
+		// Documents written by older versions may have any _id.
+		var doc struct {
+			ID    interface{} `bson:"_id"`
+			Word  string      `bson:"word"`
+			Key   string      `bson:"key"`
+			Lemma string      `bson:"lemma"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return updated, fmt.Errorf("%w: %v", ErrInvalidEntry, err)
+		}
+
+		key := NormalizeKey(doc.Word, d.options.StripAccents)
+		lemma := LemmaFor(d.options.Language, key)
+		if doc.Key == key && doc.Lemma == lemma {
+			continue
+		}
+		update := bson.M{"$set": bson.M{"key": key, "lemma": lemma}}
+		if lemma == "" {
+			update = bson.M{"$set": bson.M{"key": key}, "$unset": bson.M{"lemma": ""}}
+		}
+		batch = append(batch, mongo.NewUpdateOneModel().SetFilter(bson.M{"_id": doc.ID}).SetUpdate(update))
+
+		if len(batch) == reindexBatchSize {
+			if err := flush(); err != nil {
+				return updated, err
+			}
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return updated, fmt.Errorf("error reindexing words: %w", classify(err))
+	}
+	return updated, flush()
+}
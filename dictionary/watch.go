@@ -0,0 +1,106 @@
+package dictionary
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// changeStreamNotSupported is the MongoDB error code of change streams on a
+// standalone server.
+const changeStreamNotSupported = 40573
+
+// changeEvent is the part of a change stream event used to invalidate the cache.
+type changeEvent struct {
+	OperationType string `bson:"operationType"`
+	DocumentKey   struct {
+		ID primitive.ObjectID `bson:"_id"`
+	} `bson:"documentKey"`
+	FullDocument *Entry `bson:"fullDocument"`
+}
+
+// WatchChanges follows the change stream of the collection and invalidates the
+// cached lookups of changed entries, so that servers sharing the database do not
+// serve stale definitions. It returns when ctx is done or the dictionary is closed,
+// right away when the cache is disabled, and when the server does not support
+// change streams, which require a replica set. Other errors are reported to onError
+// and the stream is reopened, after purging the cache since changes may have been missed.
+func (d *Dictionary) WatchChanges(ctx context.Context, onError func(error)) {
+	if d.cache == nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(d.closed, cancel)
+	defer stop()
+
+	const maxBackoff = time.Minute
+	backoff := time.Second
+	for {
+		opened, err := d.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		var commandErr mongo.CommandError
+		if errors.As(err, &commandErr) && commandErr.Code == changeStreamNotSupported {
+			onError(fmt.Errorf("change streams are not supported, cached lookups expire after their TTL: %w", err))
+			return
+		}
+		d.cache.purge()
+		onError(fmt.Errorf("error watching dictionary changes: %w", err))
+
+		if opened {
+			backoff = time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// watch invalidates the cache from one change stream until it fails. It reports
+// whether the stream was opened.
+func (d *Dictionary) watch(ctx context.Context) (bool, error) {
+	stream, err := d.collection.Watch(ctx, mongo.Pipeline{}, options.ChangeStream().SetFullDocument(options.UpdateLookup))
+	if err != nil {
+		return false, classify(err)
+	}
+	defer stream.Close(context.Background())
+
+	// Changes made before the stream was opened may have been missed.
+	d.cache.purge()
+
+	for stream.Next(ctx) {
+		var event changeEvent
+		if err := stream.Decode(&event); err != nil {
+			d.cache.purge()
+			continue
+		}
+
+		switch event.OperationType {
+		case "insert", "update", "replace", "delete":
+			// Updates look the document up, which may be gone since; deletes never carry it.
+			entry := Entry{ID: event.DocumentKey.ID}
+			if event.FullDocument != nil {
+				entry = *event.FullDocument
+			}
+			d.cache.invalidate(entry)
+		default:
+			// The collection was dropped or renamed.
+			d.cache.purge()
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return true, classify(err)
+	}
+	return true, errors.New("change stream closed")
+}
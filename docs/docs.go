@@ -0,0 +1,46 @@
+// docs/docs.go
+package docs
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+)
+
+// OpenAPI is the OpenAPI 3 document describing every route of the server.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+//go:embed index.html
+var page []byte
+
+// Spec is the part of the OpenAPI document needed to check it against the router.
+type Spec struct {
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// ParseSpec decodes the embedded OpenAPI document.
+func ParseSpec() (Spec, error) {
+	var spec Spec
+	err := json.Unmarshal(OpenAPI, &spec)
+	return spec, err
+}
+
+// SpecHandler serves the OpenAPI document.
+func SpecHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(OpenAPI)
+	}
+}
+
+// PageHandler serves a page rendering the OpenAPI document. The page is
+// self-contained, so that the docs work without access to a CDN.
+func PageHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'unsafe-inline'; style-src 'unsafe-inline'")
+		w.Write(page)
+	}
+}
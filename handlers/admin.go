@@ -0,0 +1,95 @@
+// admin.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"estiam/auth"
+	"estiam/config"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// KeyRequest is the body of a request creating an API key.
+// Roles holds role names ("reader", "editor", "admin") or scopes.
+type KeyRequest struct {
+	Name  string   `json:"name"`
+	Roles []string `json:"roles"`
+}
+
+// CreatedKey is the response to a key creation. Token is only ever shown here.
+type CreatedKey struct {
+	Key   auth.APIKey `json:"key"`
+	Token string      `json:"token"`
+}
+
+// ConfigHandler serves the effective configuration with its secrets redacted.
+func ConfigHandler(cfg config.Config) http.HandlerFunc {
+	redacted := cfg.Redacted()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		jsonResponse(w, redacted)
+	}
+}
+
+// CreateKeyHandler creates an API key and returns its token.
+func CreateKeyHandler(keys *auth.KeyManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req KeyRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+
+		key, token, err := keys.Create(r.Context(), req.Name, req.Roles)
+		if err != nil {
+			handleKeyError(w, r, "Error creating api key", err)
+			return
+		}
+
+		w.Header().Set("Location", "/admin/keys/"+key.ID)
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(CreatedKey{Key: key, Token: token})
+	}
+}
+
+// ListKeysHandler lists every API key without its secret.
+func ListKeysHandler(keys *auth.KeyManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		list, err := keys.List(r.Context())
+		if err != nil {
+			handleKeyError(w, r, "Error listing api keys", err)
+			return
+		}
+
+		jsonResponse(w, map[string][]auth.APIKey{"keys": list})
+	}
+}
+
+// RevokeKeyHandler revokes an API key.
+func RevokeKeyHandler(keys *auth.KeyManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := keys.Revoke(r.Context(), mux.Vars(r)["id"]); err != nil {
+			handleKeyError(w, r, "Error revoking api key", err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleKeyError maps an error returned by the key manager to a problem response.
+func handleKeyError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, auth.ErrKeyNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, auth.ErrKeyRevoked):
+		status = http.StatusConflict
+	case errors.Is(err, auth.ErrInvalidKeyName), errors.Is(err, auth.ErrInvalidScope):
+		status = http.StatusBadRequest
+	}
+	handleError(w, r, message, err, status)
+}
@@ -0,0 +1,80 @@
+// handlers/conditional.go
+package handlers
+
+import (
+	"estiam/dictionary"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// entityTag returns tag in the given format. Formats other than JSON get a
+// suffix, so that caches never take one format for another.
+func entityTag(tag string, format string) string {
+	if format == FormatJSON {
+		return tag
+	}
+	return strings.TrimSuffix(tag, `"`) + "-" + format + `"`
+}
+
+// setValidators sets the ETag and Last-Modified headers of entry in the given format.
+func setValidators(w http.ResponseWriter, entry dictionary.Entry, format string) {
+	setTagAndTime(w, entityTag(entry.ETag(), format), entry.UpdatedAt)
+}
+
+// setListValidators sets the ETag and Last-Modified headers of the word list
+// in version v, in the given format.
+func setListValidators(w http.ResponseWriter, v dictionary.ListVersion, format string) {
+	setTagAndTime(w, entityTag(v.ETag(), format), v.UpdatedAt)
+}
+
+// setTagAndTime sets the ETag header to tag, and Last-Modified to modified unless it is zero.
+func setTagAndTime(w http.ResponseWriter, tag string, modified time.Time) {
+	w.Header().Set("ETag", tag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+}
+
+// notModified answers 304 and returns true when the conditional GET r already
+// holds the current representation of a resource last changed at modified:
+// If-None-Match lists its ETag, or, without If-None-Match, If-Modified-Since is
+// not older than modified. The validators must have been set beforehand.
+func notModified(w http.ResponseWriter, r *http.Request, modified time.Time) bool {
+	if header := r.Header.Get("If-None-Match"); header != "" {
+		current := w.Header().Get("ETag")
+		for _, tag := range entityTags(header) {
+			// If-None-Match uses the weak comparison.
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+				w.WriteHeader(http.StatusNotModified)
+				return true
+			}
+		}
+		return false
+	}
+
+	if header := r.Header.Get("If-Modified-Since"); header != "" && !modified.IsZero() {
+		since, err := http.ParseTime(header)
+		if err == nil && !modified.Truncate(time.Second).After(since) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// condition returns the precondition of a change requested by r from its If-Match header.
+func condition(r *http.Request) dictionary.Condition {
+	return dictionary.Condition{IfMatch: entityTags(strings.Join(r.Header.Values("If-Match"), ","))}
+}
+
+// entityTags splits a list of entity tags, such as an If-None-Match header.
+func entityTags(header string) []string {
+	var tags []string
+	for _, tag := range strings.Split(header, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
@@ -0,0 +1,69 @@
+// handlers/decode.go
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"estiam/middleware"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// MediaTypeMergePatch is the media type of JSON merge patches (RFC 7396).
+const MediaTypeMergePatch = "application/merge-patch+json"
+
+// decodeJSON decodes the body of r into v. The body must be a single JSON value
+// without unknown fields, sent as application/json or one of the other media
+// types given. It answers the request itself and returns false when the body is
+// rejected: 415 for another Content-Type, 413 past the body size limit, 400 otherwise.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}, mediaTypes ...string) bool {
+	mediaTypes = append([]string{"application/json"}, mediaTypes...)
+	if !acceptedMediaType(r.Header.Get("Content-Type"), mediaTypes) {
+		if r.Method == http.MethodPatch {
+			w.Header().Set("Accept-Patch", strings.Join(mediaTypes, ", "))
+		}
+		middleware.HandleError(w, fmt.Sprintf("Content-Type must be %s", strings.Join(mediaTypes, " or ")), http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	err := decoder.Decode(v)
+	if err == nil {
+		if extra := decoder.Decode(&json.RawMessage{}); extra != io.EOF {
+			err = errors.New("body must contain a single JSON value")
+			var tooLarge *http.MaxBytesError
+			if errors.As(extra, &tooLarge) {
+				err = extra
+			}
+		}
+	}
+
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		middleware.HandleError(w, fmt.Sprintf("Request body exceeds %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return false
+	case err != nil:
+		middleware.HandleError(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// acceptedMediaType reports whether the Content-Type header names one of mediaTypes.
+func acceptedMediaType(header string, mediaTypes []string) bool {
+	mediaType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return false
+	}
+	for _, accepted := range mediaTypes {
+		if mediaType == accepted {
+			return true
+		}
+	}
+	return false
+}
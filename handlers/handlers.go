@@ -3,6 +3,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"estiam/dictionary"
 	"estiam/middleware"
 	"fmt"
@@ -12,39 +14,90 @@ import (
 	"github.com/gorilla/mux"
 )
 
+// Definition is the response to a lookup.
+type Definition struct {
+	XMLName xml.Name `json:"-" xml:"definition"`
+	// Word is the word requested.
+	Word       string `json:"word" xml:"word"`
+	Definition string `json:"definition" xml:"definition"`
+	// Match is the form of the word that matched, and MatchedWord the stored word.
+	Match       string `json:"match" xml:"match"`
+	MatchedWord string `json:"matched_word" xml:"matched_word"`
+}
+
+// definitionOf returns the public representation of a stored entry, as a lookup
+// of its own word would. Internal fields such as the version stay out of it.
+func definitionOf(entry dictionary.Entry) Definition {
+	return Definition{
+		Word:        entry.Word,
+		Definition:  entry.Definition,
+		Match:       string(dictionary.MatchExact),
+		MatchedWord: entry.Word,
+	}
+}
+
+// Records returns the definition as a CSV header and row.
+func (d Definition) Records() [][]string {
+	return [][]string{
+		{"word", "definition", "match", "matched_word"},
+		{d.Word, d.Definition, d.Match, d.MatchedWord},
+	}
+}
+
+// Text returns the definition as "word: definition".
+func (d Definition) Text() string {
+	return d.Word + ": " + d.Definition + "\n"
+}
+
+// WordList is the response to a listing.
+type WordList struct {
+	XMLName xml.Name `json:"-" xml:"words"`
+	Words   []string `json:"words" xml:"word"`
+}
+
+// Records returns the words as a CSV header and one row per word.
+func (l WordList) Records() [][]string {
+	records := [][]string{{"word"}}
+	for _, word := range l.Words {
+		records = append(records, []string{word})
+	}
+	return records
+}
+
+// Text returns one word per line.
+func (l WordList) Text() string {
+	var b strings.Builder
+	for _, word := range l.Words {
+		b.WriteString(word + "\n")
+	}
+	return b.String()
+}
+
 // AddEntryHandler is a handler for adding entries to the dictionary.
 func AddEntryHandler(d *dictionary.Dictionary) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Decode the incoming JSON request into an EntryOperation.
 		var entry dictionary.EntryOperation
-		err := json.NewDecoder(r.Body).Decode(&entry)
-		if err != nil {
-			fmt.Println("Error decoding JSON:", err)
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
+		if !decodeJSON(w, r, &entry) {
 			return
 		}
 
-		// Validate the incoming data.
-		err = middleware.ValidateData(entry.Word, entry.Definition)
+		// Validate the incoming data; this also trims and NFC-normalizes word and definition.
+		word, definition, err := middleware.DefaultValidator.Validate(entry.Word, entry.Definition)
 		if err != nil {
-			middleware.HandleError(w, fmt.Sprintf("Error validating data: %v", err), http.StatusBadRequest)
+			middleware.HandleValidationError(w, err)
 			return
 		}
 
-		fmt.Printf("Received JSON: %+v\n", entry)
-
-		// Trim leading and trailing whitespaces from word and definition.
-		word := strings.TrimSpace(entry.Word)
-		definition := strings.TrimSpace(entry.Definition)
-
 		// Add the word to the dictionary.
-		message, err := d.Add(word, definition)
+		result, err := d.AddContext(r.Context(), word, definition)
 
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Error adding word: %v", err), http.StatusInternalServerError)
+			handleDictionaryError(w, r, "Error adding word", err)
 			return
 		}
 
+		message := fmt.Sprintf("Word '%s' Added successfully", result.Entry.Word)
 		jsonResponse(w, map[string]string{"message": message})
 	}
 }
@@ -52,51 +105,128 @@ func AddEntryHandler(d *dictionary.Dictionary) http.HandlerFunc {
 // GetDefinitionHandler retrieves the definition of a word from the dictionary.
 func GetDefinitionHandler(d *dictionary.Dictionary) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Pick the response format before reaching the dictionary.
+		format, ok := Negotiate(w, r)
+		if !ok {
+			return
+		}
+
 		// Extract the word parameter from the request.
 		params := mux.Vars(r)
 		word := params["word"]
 
-		// Get the definition of the word from the dictionary.
-		entry, err := d.Get(word)
+		// Look the word up, falling back to its normalized form and lemma.
+		match, err := d.LookupContext(r.Context(), word)
 		if err != nil {
-			middleware.HandleError(w, fmt.Sprintf("Error getting word: %v", err), http.StatusNotFound)
+			handleDictionaryError(w, r, "Error getting word", err)
 			return
 		}
 
-		// Prepare and send the response.
-		response := map[string]string{"word": word, "definition": entry.String()}
-		jsonResponse(w, response)
+		// Answer conditional requests for an unchanged entry with 304.
+		setValidators(w, match.Entry, format)
+		if notModified(w, r, match.Entry.UpdatedAt) {
+			return
+		}
+
+		// Prepare and send the response, telling which form matched.
+		Respond(w, format, Definition{
+			Word:        word,
+			Definition:  match.Entry.String(),
+			Match:       string(match.Kind),
+			MatchedWord: match.Entry.Word,
+		})
 	}
 }
 
 // RemoveEntryHandler removes a word and its definition from the dictionary.
+// With If-Match, it only removes the entry in one of the listed states.
 func RemoveEntryHandler(d *dictionary.Dictionary) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Extract the word parameter from the request.
 		params := mux.Vars(r)
 		word := params["word"]
 
-		// Remove the word from the dictionary.
-		message, err := d.Remove(word)
+		// Remove the word from the dictionary; a missing word answers 404, a stale If-Match 412.
+		_, err := d.RemoveIfContext(r.Context(), word, condition(r))
 		if err != nil {
-			middleware.HandleError(w, fmt.Sprintf("Error removing word: %v", err), http.StatusInternalServerError)
+			handleDictionaryError(w, r, "Error removing word", err)
 			return
 		}
 
-		// Prepare and send the response.
-		jsonResponse(w, map[string]string{"message": message})
+		// The word is gone; there is nothing left to send.
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
 
 // ListWordsHandler retrieves a list of all words in the dictionary.
 func ListWordsHandler(d *dictionary.Dictionary) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		// Pick the response format before reaching the dictionary.
+		format, ok := Negotiate(w, r)
+		if !ok {
+			return
+		}
+
+		// Answer conditional requests for an unchanged list with 304. The version
+		// is read first, so that a change made meanwhile is never hidden by it.
+		version, err := d.ListVersionContext(r.Context())
+		if err != nil {
+			handleDictionaryError(w, r, "Error listing words", err)
+			return
+		}
+		setListValidators(w, version, format)
+		if notModified(w, r, version.UpdatedAt) {
+			return
+		}
+
 		// Get the list of words from the dictionary.
-		words, _ := d.List()
+		words, err := d.ListContext(r.Context())
+		if err != nil {
+			handleDictionaryError(w, r, "Error listing words", err)
+			return
+		}
 
 		// Prepare and send the response.
-		response := map[string][]string{"words": words}
-		jsonResponse(w, response)
+		Respond(w, format, WordList{Words: words})
+	}
+}
+
+// handleDictionaryError maps an error returned by the dictionary to a problem response.
+func handleDictionaryError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	if errors.Is(err, dictionary.ErrUnavailable) {
+		w.Header().Set("Retry-After", "5")
+	}
+	handleError(w, r, message, err, statusFromError(err))
+}
+
+// handleError sends a problem response for err. Client errors carry the error
+// as their detail; server errors only carry message, so that backend errors do
+// not leak to clients, and err is recorded in the access log instead.
+func handleError(w http.ResponseWriter, r *http.Request, message string, err error, status int) {
+	if status < http.StatusInternalServerError {
+		middleware.HandleError(w, fmt.Sprintf("%s: %v", message, err), status)
+		return
+	}
+
+	middleware.LogError(r.Context(), fmt.Errorf("%s: %w", message, err))
+	middleware.HandleError(w, message, status)
+}
+
+// statusFromError returns the HTTP status code matching a dictionary sentinel error.
+func statusFromError(err error) int {
+	switch {
+	case errors.Is(err, dictionary.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, dictionary.ErrExists):
+		return http.StatusConflict
+	case errors.Is(err, dictionary.ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, dictionary.ErrUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, dictionary.ErrTimeout):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
 	}
 }
 
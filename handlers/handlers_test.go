@@ -0,0 +1,264 @@
+// handlers_test.go
+package handlers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"estiam/auth"
+	"estiam/handlers"
+	"estiam/middleware"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessHandler(t *testing.T) {
+	up := func(ctx context.Context) error { return nil }
+	down := func(ctx context.Context) error { return errors.New("no reachable servers") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+	serve := func(checks map[string]handlers.Check) (int, handlers.Readiness) {
+		w := httptest.NewRecorder()
+		handlers.ReadinessHandler(checks, 50*time.Millisecond, logger).ServeHTTP(w, httptest.NewRequest("GET", "/readyz", nil))
+		assert.NotContains(t, w.Body.String(), "no reachable servers")
+		var readiness handlers.Readiness
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &readiness))
+		return w.Code, readiness
+	}
+
+	// 1. All checks pass.
+	code, readiness := serve(map[string]handlers.Check{"mongodb": up})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ready", readiness.Status)
+	assert.Equal(t, "up", readiness.Checks["mongodb"].Status)
+
+	// 2. A failing or hanging check makes the server unavailable, with the status of each dependency.
+	code, readiness = serve(map[string]handlers.Check{"mongodb": down, "cache": slow, "other": up})
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", readiness.Status)
+	assert.Equal(t, "down", readiness.Checks["mongodb"].Status)
+	assert.Equal(t, "down", readiness.Checks["cache"].Status)
+	assert.Equal(t, "up", readiness.Checks["other"].Status)
+
+	// 3. The errors are logged instead of being served.
+	assert.Contains(t, logs.String(), `"check":"mongodb","error":"no reachable servers"`)
+}
+
+func TestHealthHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	handlers.HealthHandler().ServeHTTP(w, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+}
+
+func TestPutWordHandlerRejectsMismatchedWord(t *testing.T) {
+	// 1. A body naming another word is rejected before the dictionary is reached.
+	r := mux.NewRouter()
+	r.Handle("/v1/words/{word}", handlers.PutWordHandler(nil)).Methods("PUT")
+
+	put := func(body string) int {
+		req := httptest.NewRequest("PUT", "/v1/words/chat", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusBadRequest, put(`{"word":"chien","definition":"A dog."}`))
+
+	// 2. So is a body without a definition.
+	assert.Equal(t, http.StatusBadRequest, put(`{}`))
+}
+
+func TestRequestBodyValidation(t *testing.T) {
+	// 1. Route bodies through a 64-byte limit; every rejection happens before the dictionary is reached.
+	r := mux.NewRouter()
+	r.Use(middleware.MaxBodySize(64))
+	r.Handle("/add", handlers.AddEntryHandler(nil)).Methods("POST")
+	r.Handle("/v1/words/{word}", handlers.PatchWordHandler(nil)).Methods("PATCH")
+	serve := func(method, path, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		// Hide the length, so that the handler reads past the limit.
+		req.ContentLength = -1
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 2. Bodies must be JSON; PATCH also accepts merge patches.
+	w := serve("POST", "/add", "text/plain", `{"word":"chat","definition":"A cat."}`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	w = serve("PATCH", "/v1/words/chat", "application/xml", `<definition/>`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Equal(t, "application/json, application/merge-patch+json", w.Header().Get("Accept-Patch"))
+	w = serve("PATCH", "/v1/words/chat", "application/merge-patch+json; charset=utf-8", `{"definition":"A cat.","version":3}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "the media type is accepted, the unknown field is not")
+
+	// 3. Unknown fields, trailing values and garbage are rejected.
+	for _, body := range []string{
+		`{"word":"chat","definition":"A cat.","lang":"fr"}`,
+		`{"word":"chat","definition":"A cat."}{"word":"chien"}`,
+		`{"word":"chat","definition":"A cat."} garbage`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, serve("POST", "/add", "application/json", body).Code, body)
+	}
+
+	// 4. Bodies past the limit are answered 413, even after a first valid value.
+	long := `{"word":"chat","definition":"` + strings.Repeat("a", 100) + `"}`
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve("POST", "/add", "application/json", long).Code)
+	padded := `{"word":"chat","definition":"A cat."}` + strings.Repeat(" ", 100)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, serve("POST", "/add", "application/json", padded).Code)
+}
+
+func TestWordPath(t *testing.T) {
+	assert.Equal(t, "/v1/words/chat", handlers.WordPath("chat"))
+	assert.Equal(t, "/v1/words/caf%C3%A9%20cr%C3%A8me", handlers.WordPath("café crème"))
+}
+
+func TestNegotiate(t *testing.T) {
+	negotiate := func(target, accept string) (string, int) {
+		r := httptest.NewRequest("GET", target, nil)
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		format, ok := handlers.Negotiate(w, r)
+		if !ok {
+			return "", w.Code
+		}
+		return format, http.StatusOK
+	}
+
+	// 1. JSON is the default, and wildcards prefer it.
+	format, _ := negotiate("/list", "")
+	assert.Equal(t, handlers.FormatJSON, format)
+	format, _ = negotiate("/list", "*/*")
+	assert.Equal(t, handlers.FormatJSON, format)
+
+	// 2. The Accept header picks the format with the highest quality.
+	format, _ = negotiate("/list", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+	assert.Equal(t, handlers.FormatHTML, format)
+	format, _ = negotiate("/list", "application/json;q=0.5, text/csv")
+	assert.Equal(t, handlers.FormatCSV, format)
+	format, _ = negotiate("/list", "text/*;q=0.9, text/plain;q=0.1, text/xml;q=0")
+	assert.Equal(t, handlers.FormatCSV, format)
+
+	// 3. The format parameter overrides the Accept header.
+	format, _ = negotiate("/list?format=text", "application/json")
+	assert.Equal(t, handlers.FormatText, format)
+
+	// 4. Unsupported types and formats answer 406.
+	_, code := negotiate("/list", "image/png")
+	assert.Equal(t, http.StatusNotAcceptable, code)
+	_, code = negotiate("/list", "application/json;q=0")
+	assert.Equal(t, http.StatusNotAcceptable, code)
+	_, code = negotiate("/list?format=yaml", "")
+	assert.Equal(t, http.StatusNotAcceptable, code)
+}
+
+func TestRespond(t *testing.T) {
+	definition := handlers.Definition{Word: "chat", Definition: "=A <small> cat", Match: "exact", MatchedWord: "chat"}
+	respond := func(format string, v handlers.Representation) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handlers.Respond(w, format, v)
+		return w
+	}
+
+	// 1. JSON keeps the fields of the original response.
+	w := respond(handlers.FormatJSON, definition)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"word":"chat","definition":"=A <small> cat","match":"exact","matched_word":"chat"}`, w.Body.String())
+
+	// 2. XML, CSV, plain text and HTML.
+	w = respond(handlers.FormatXML, definition)
+	assert.Contains(t, w.Body.String(), "<definition><word>chat</word><definition>=A &lt;small&gt; cat</definition>")
+
+	w = respond(handlers.FormatCSV, definition)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "word,definition,match,matched_word\nchat,=A <small> cat,exact,chat\n", w.Body.String())
+
+	w = respond(handlers.FormatText, definition)
+	assert.Equal(t, "chat: =A <small> cat\n", w.Body.String())
+
+	w = respond(handlers.FormatHTML, definition)
+	assert.Contains(t, w.Body.String(), "<td>=A &lt;small&gt; cat</td>")
+
+	// 3. Word lists have one row or line per word.
+	list := handlers.WordList{Words: []string{"chat", "chien"}}
+	assert.Equal(t, "chat\nchien\n", respond(handlers.FormatText, list).Body.String())
+	assert.Equal(t, "word\nchat\nchien\n", respond(handlers.FormatCSV, list).Body.String())
+	assert.Contains(t, respond(handlers.FormatXML, list).Body.String(), "<words><word>chat</word><word>chien</word></words>")
+	assert.JSONEq(t, `{"words":["chat","chien"]}`, respond(handlers.FormatJSON, list).Body.String())
+}
+
+// brokenKeyStore is an auth.KeyStore whose backend fails every call.
+type brokenKeyStore struct{}
+
+var errBackend = errors.New("server selection error: mongo-1:27017 unreachable")
+
+func (brokenKeyStore) Insert(ctx context.Context, key auth.APIKey) error { return errBackend }
+func (brokenKeyStore) Find(ctx context.Context, id string) (auth.APIKey, error) {
+	return auth.APIKey{}, errBackend
+}
+func (brokenKeyStore) List(ctx context.Context) ([]auth.APIKey, error)           { return nil, errBackend }
+func (brokenKeyStore) Revoke(ctx context.Context, id string, at time.Time) error { return errBackend }
+func (brokenKeyStore) Touch(ctx context.Context, id string, at time.Time) error  { return errBackend }
+
+func TestServerErrorsAreNotLeaked(t *testing.T) {
+	// 1. Serve a failing admin route behind the access log.
+	path := filepath.Join(t.TempDir(), "access.log")
+	logger, err := middleware.NewLogger(path)
+	assert.NoError(t, err)
+	defer logger.Close()
+
+	handler := logger.MiddlewareFunc()(handlers.ListKeysHandler(auth.NewKeyManager(brokenKeyStore{})))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/admin/keys", nil))
+
+	// 2. The client gets a generic detail.
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	var problem middleware.Problem
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "Error listing api keys", problem.Detail)
+	assert.NotContains(t, w.Body.String(), "mongo-1")
+
+	// 3. The cause is kept in the access log.
+	logs, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(logs), `"error":"Error listing api keys: server selection error: mongo-1:27017 unreachable"`)
+}
+
+func TestRespondEscapesCSVFormulas(t *testing.T) {
+	defer func() { handlers.EscapeCSVFormulas = false }()
+	respond := func(definition string) string {
+		w := httptest.NewRecorder()
+		handlers.Respond(w, handlers.FormatCSV, handlers.Definition{Word: "chat", Definition: definition, Match: "exact", MatchedWord: "chat"})
+		return w.Body.String()
+	}
+
+	// 1. By default, cells are sent as stored, quoted only as CSV requires.
+	assert.Equal(t, "word,definition,match,matched_word\nchat,\"-ish, \"\"small\"\"\",exact,chat\n", respond(`-ish, "small"`))
+	assert.Equal(t, "word,definition,match,matched_word\nchat,@SUM(A1),exact,chat\n", respond("@SUM(A1)"))
+
+	// 2. When enabled, cells a spreadsheet would run as a formula are prefixed with a quote.
+	handlers.EscapeCSVFormulas = true
+	assert.Equal(t, "word,definition,match,matched_word\nchat,\"'-ish, \"\"small\"\"\",exact,chat\n", respond(`-ish, "small"`))
+	assert.Equal(t, "word,definition,match,matched_word\nchat,'@SUM(A1),exact,chat\n", respond("@SUM(A1)"))
+	assert.Equal(t, "word,definition,match,matched_word\nchat,a cat,exact,chat\n", respond("a cat"))
+}
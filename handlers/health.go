@@ -0,0 +1,87 @@
+// health.go
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check reports whether a dependency, such as MongoDB, is usable.
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of one readiness check. It carries no error
+// message, since the readiness endpoint is served without authentication.
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+}
+
+// Readiness is the response of the readiness endpoint.
+type Readiness struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// HealthHandler reports that the process is alive. It checks no dependency, so
+// that an orchestrator does not restart the server because the database is down.
+func HealthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		jsonResponse(w, map[string]string{"status": "ok"})
+	}
+}
+
+// ReadinessHandler runs checks concurrently, each bounded by timeout, and reports
+// the status of every dependency. It answers 503 unless all checks pass, so that
+// load balancers stop routing requests to a server that cannot serve them. The
+// errors of failed checks are logged to logger, or slog.Default() if nil.
+func ReadinessHandler(checks map[string]Check, timeout time.Duration, logger *slog.Logger) http.HandlerFunc {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		readiness := Readiness{Status: "ready", Checks: map[string]CheckResult{}}
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for name, check := range checks {
+			wg.Add(1)
+			go func(name string, check Check) {
+				defer wg.Done()
+
+				start := time.Now()
+				err := check(ctx)
+				result := CheckResult{Status: "up", LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+				if err != nil {
+					result.Status = "down"
+					logger.LogAttrs(ctx, slog.LevelWarn, "readiness check failed",
+						slog.String("check", name),
+						slog.String("error", err.Error()),
+					)
+				}
+
+				mu.Lock()
+				defer mu.Unlock()
+				readiness.Checks[name] = result
+				if err != nil {
+					readiness.Status = "unavailable"
+				}
+			}(name, check)
+		}
+		wg.Wait()
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		if readiness.Status != "ready" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(readiness)
+	}
+}
@@ -0,0 +1,208 @@
+// handlers/negotiate.go
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"estiam/middleware"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Response formats, as accepted by the format query parameter.
+const (
+	FormatJSON = "json"
+	FormatXML  = "xml"
+	FormatCSV  = "csv"
+	FormatText = "text"
+	FormatHTML = "html"
+)
+
+// formats lists the response formats in order of preference, with the media
+// types that select them and the content type they are sent with.
+var formats = []struct {
+	name        string
+	mediaTypes  []string
+	contentType string
+}{
+	{FormatJSON, []string{"application/json"}, "application/json"},
+	{FormatXML, []string{"application/xml", "text/xml"}, "application/xml; charset=utf-8"},
+	{FormatCSV, []string{"text/csv"}, "text/csv; charset=utf-8"},
+	{FormatText, []string{"text/plain"}, "text/plain; charset=utf-8"},
+	{FormatHTML, []string{"text/html"}, "text/html; charset=utf-8"},
+}
+
+// Representation is a response body that can be written in every format.
+// JSON and XML encode the value itself.
+type Representation interface {
+	// Records returns the CSV rows, header first; HTML renders them as a table.
+	Records() [][]string
+	// Text returns the plain text form, e.g. "word: definition".
+	Text() string
+}
+
+// Negotiate picks the response format of r from its format query parameter, or
+// else from its Accept header, defaulting to JSON. It answers 406 and returns
+// false when no supported format is acceptable.
+func Negotiate(w http.ResponseWriter, r *http.Request) (string, bool) {
+	w.Header().Add("Vary", "Accept")
+
+	if name := r.URL.Query().Get("format"); name != "" {
+		for _, f := range formats {
+			if f.name == name {
+				return name, true
+			}
+		}
+		middleware.HandleError(w, fmt.Sprintf("Unsupported format %q", name), http.StatusNotAcceptable)
+		return "", false
+	}
+
+	accept := r.Header.Values("Accept")
+	if len(accept) == 0 {
+		return FormatJSON, true
+	}
+	ranges := parseAccept(strings.Join(accept, ","))
+
+	best, bestQuality := "", 0.0
+	for _, f := range formats {
+		for _, mediaType := range f.mediaTypes {
+			if q := quality(ranges, mediaType); q > bestQuality {
+				best, bestQuality = f.name, q
+			}
+		}
+	}
+	if best == "" {
+		middleware.HandleError(w, "None of the accepted media types is supported; use JSON, XML, CSV, plain text or HTML", http.StatusNotAcceptable)
+		return "", false
+	}
+	return best, true
+}
+
+// mediaRange is one media range of an Accept header with its quality.
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+// parseAccept parses the media ranges of an Accept header. Ranges with an invalid
+// quality are ignored.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(params[0]))
+		if mediaType == "" {
+			continue
+		}
+
+		q := 1.0
+		valid := true
+		for _, param := range params[1:] {
+			name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if strings.EqualFold(name, "q") {
+				var err error
+				q, err = strconv.ParseFloat(value, 64)
+				valid = err == nil && q >= 0 && q <= 1
+			}
+		}
+		if valid {
+			ranges = append(ranges, mediaRange{mediaType, q})
+		}
+	}
+	return ranges
+}
+
+// quality returns the quality of mediaType given by the most specific matching range.
+func quality(ranges []mediaRange, mediaType string) float64 {
+	major, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, 0
+	for _, r := range ranges {
+		s := 0
+		switch r.mediaType {
+		case mediaType:
+			s = 3
+		case major + "/*":
+			s = 2
+		case "*/*":
+			s = 1
+		}
+		if s > specificity {
+			q, specificity = r.q, s
+		}
+	}
+	return q
+}
+
+// Respond writes v in the given format, as returned by Negotiate.
+func Respond(w http.ResponseWriter, format string, v Representation) {
+	for _, f := range formats {
+		if f.name == format {
+			w.Header().Set("Content-Type", f.contentType)
+		}
+	}
+
+	switch format {
+	case FormatXML:
+		io.WriteString(w, xml.Header)
+		xml.NewEncoder(w).Encode(v)
+	case FormatCSV:
+		writeCSV(w, v.Records())
+	case FormatText:
+		io.WriteString(w, v.Text())
+	case FormatHTML:
+		w.Header().Set("Content-Security-Policy", "default-src 'none'")
+		records := v.Records()
+		htmlPage.Execute(w, struct {
+			Header []string
+			Rows   [][]string
+		}{records[0], records[1:]})
+	default:
+		json.NewEncoder(w).Encode(v)
+	}
+}
+
+// EscapeCSVFormulas prefixes the CSV cells that a spreadsheet would run as a
+// formula, those starting with =, +, -, @, a tab or a carriage return, with a
+// quote, so that a definition cannot inject one. It is off by default since
+// it alters the data: "-ish" is sent as "'-ish". main sets it from the
+// server.csv_escape_formulas option.
+var EscapeCSVFormulas = false
+
+// writeCSV writes records as CSV, escaping formulas when EscapeCSVFormulas is set.
+func writeCSV(w io.Writer, records [][]string) {
+	writer := csv.NewWriter(w)
+	for _, record := range records {
+		if EscapeCSVFormulas {
+			escaped := make([]string, len(record))
+			for i, cell := range record {
+				if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+					cell = "'" + cell
+				}
+				escaped[i] = cell
+			}
+			record = escaped
+		}
+		writer.Write(record)
+	}
+	writer.Flush()
+}
+
+// htmlPage renders records as a minimal HTML table, for browsers.
+var htmlPage = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head><meta charset="utf-8"><title>Dictionary</title></head>
+<body>
+<table>
+<thead><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+</body>
+</html>
+`))
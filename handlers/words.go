@@ -0,0 +1,127 @@
+// handlers/words.go
+package handlers
+
+import (
+	"encoding/json"
+	"estiam/dictionary"
+	"estiam/middleware"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gorilla/mux"
+)
+
+// WordPath returns the path of the /v1/words resource of word.
+func WordPath(word string) string {
+	return "/v1/words/" + url.PathEscape(word)
+}
+
+// CreateWordHandler adds a word to the dictionary, answering 201 with the location
+// of the new resource, or 409 when the word is already in the dictionary.
+func CreateWordHandler(d *dictionary.Dictionary) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var entry dictionary.EntryOperation
+		if !decodeJSON(w, r, &entry) {
+			return
+		}
+
+		word, definition, err := middleware.DefaultValidator.Validate(entry.Word, entry.Definition)
+		if err != nil {
+			middleware.HandleValidationError(w, err)
+			return
+		}
+
+		result, err := d.CreateContext(r.Context(), word, definition)
+		if err != nil {
+			handleDictionaryError(w, r, "Error creating word", err)
+			return
+		}
+
+		setValidators(w, result.Entry, FormatJSON)
+		createdResponse(w, result.Entry)
+	}
+}
+
+// PutWordHandler sets the definition of the word in the path, creating the word
+// when needed: it answers 201 with its location when created, 200 when replaced.
+// With If-Match, it only replaces the entry in one of the listed states.
+func PutWordHandler(d *dictionary.Dictionary) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		word, definition, ok := decodeWordBody(w, r)
+		if !ok {
+			return
+		}
+
+		entry, created, err := d.SetIfContext(r.Context(), word, definition, condition(r))
+		if err != nil {
+			handleDictionaryError(w, r, "Error setting word", err)
+			return
+		}
+
+		setValidators(w, entry, FormatJSON)
+		if created {
+			createdResponse(w, entry)
+			return
+		}
+		jsonResponse(w, definitionOf(entry))
+	}
+}
+
+// PatchWordHandler updates the definition of a word already in the dictionary.
+// The body is a JSON merge patch of the entry, of which only the definition may change.
+// With If-Match, it only updates the entry in one of the listed states.
+func PatchWordHandler(d *dictionary.Dictionary) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		word, definition, ok := decodeWordBody(w, r)
+		if !ok {
+			return
+		}
+
+		entry, err := d.UpdateIfContext(r.Context(), word, definition, condition(r))
+		if err != nil {
+			handleDictionaryError(w, r, "Error updating word", err)
+			return
+		}
+
+		setValidators(w, entry, FormatJSON)
+		jsonResponse(w, definitionOf(entry))
+	}
+}
+
+// decodeWordBody reads the definition sent for the word in the path, and validates
+// both. A word in the body must match the path; PATCH bodies may also be sent as
+// merge patches. It answers the request itself and returns false when the body is invalid.
+func decodeWordBody(w http.ResponseWriter, r *http.Request) (string, string, bool) {
+	var mediaTypes []string
+	if r.Method == http.MethodPatch {
+		mediaTypes = append(mediaTypes, MediaTypeMergePatch)
+	}
+
+	var entry dictionary.EntryOperation
+	if !decodeJSON(w, r, &entry, mediaTypes...) {
+		return "", "", false
+	}
+
+	word, definition, err := middleware.DefaultValidator.Validate(mux.Vars(r)["word"], entry.Definition)
+	if err != nil {
+		middleware.HandleValidationError(w, err)
+		return "", "", false
+	}
+
+	if entry.Word != "" {
+		if bodyWord, _, err := middleware.DefaultValidator.Validate(entry.Word, definition); err != nil || bodyWord != word {
+			middleware.HandleError(w, fmt.Sprintf("Word %q in the body does not match the path", entry.Word), http.StatusBadRequest)
+			return "", "", false
+		}
+	}
+	return word, definition, true
+}
+
+// createdResponse answers 201 with the location and JSON definition of a new entry.
+func createdResponse(w http.ResponseWriter, entry dictionary.Entry) {
+	w.Header().Set("Location", WordPath(entry.Word))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(definitionOf(entry))
+}
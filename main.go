@@ -2,57 +2,358 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"estiam/auth"
+	"estiam/config"
 	"estiam/dictionary"
 	"estiam/handlers"
+	"estiam/metrics"
 	"estiam/middleware"
+	"estiam/tlsutil"
+	"flag"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
-
-	"github.com/gorilla/mux"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
-// logFilename is the name of the log file.
-const logFilename = "jornale.txt"
-
 func main() {
-	// Initialize the logger for logging middleware.
-	logger, err := middleware.NewLogger(logFilename)
+	// Load the configuration from file, environment variables and flags.
+	cfg, args, err := config.Load(os.Args[0], os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		fmt.Println("Error initializing log file:", err)
+		fmt.Println("Error loading configuration:", err)
+		os.Exit(2)
+	}
+
+	// Configure the validation of new entries.
+	validator, err := newValidator(cfg.Validation)
+	if err != nil {
+		fmt.Println("Error loading configuration:", err)
+		os.Exit(2)
+	}
+	middleware.DefaultValidator = validator
+
+	// Escape formulas in CSV responses when spreadsheets are expected to open them.
+	handlers.EscapeCSVFormulas = cfg.Server.CSVEscapeFormulas
+
+	// Analyze access logs without connecting to the database.
+	if len(args) > 0 && args[0] == "analyze" {
+		if err := runAnalyze(cfg.Log.File, args[1:], os.Stdout); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Initialize the metrics, recording every dictionary operation.
+	m := metrics.New()
+	options := dictionaryOptions(cfg.Dictionary)
+	options.Observer = m.ObserveDictionary
+	options.Language = cfg.Validation.Language
+
+	// Remove duplicate words before the dictionary, which refuses them, is initialized.
+	if len(args) > 0 && args[0] == "dedupe" {
+		if err := runDedupe(cfg.Mongo, options, args[1:], os.Stdout); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
 		return
 	}
 
 	// Initialize the dictionary.
-	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "dictionary", "dictionary")
+	d, err := dictionary.NewDictionaryWithOptions(cfg.Mongo.URI, cfg.Mongo.Database, cfg.Mongo.Collection, options)
 	if err != nil {
 		fmt.Println("Error initializing dictionary:", err)
+		if errors.Is(err, dictionary.ErrDuplicateWords) {
+			fmt.Printf("Run \"%s dedupe\" to keep only the oldest entry of each word.\n", os.Args[0])
+		}
 		return
 	}
+	m.RegisterDictionarySize(d.CountContext)
+	m.RegisterDictionaryCache(d.CacheStats)
+
+	// Initialize the API key store, kept next to the dictionary.
+	keys := auth.NewKeyManager(auth.NewMongoKeyStore(d.Database().Collection(cfg.Auth.KeysCollection)))
+
+	// Run a subcommand instead of the server when one is given.
+	if len(args) > 0 {
+		if err := runCommand(d, keys, args, os.Stdout); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Select how bearer tokens are authenticated.
+	authenticator, err := newAuthenticator(cfg.Auth, keys)
+	if err != nil {
+		fmt.Println("Error initializing authentication:", err)
+		os.Exit(2)
+	}
 
-	// Create a new Gorilla Mux router.
-	r := mux.NewRouter()
+	// Accept verified client certificates as an alternative to bearer tokens.
+	if cfg.Server.TLS.ClientAuth != config.ClientAuthNone {
+		certs, err := auth.NewClientCertAuthenticator(cfg.Auth.ClientCertRoles)
+		if err != nil {
+			fmt.Println("Error initializing authentication:", err)
+			os.Exit(2)
+		}
+		authenticator = auth.Chain(authenticator, certs)
+	}
 
-	// Use the logger middleware for logging requests.
-	r.Use(logger.MiddlewareFunc())
+	// Initialize the store of daily request quotas.
+	quotas, err := newQuotaStore(cfg, d)
+	if err != nil {
+		fmt.Println("Error initializing quotas:", err)
+		return
+	}
 
-	// Use the AuthMiddleware for authentication.
-	r.Use(middleware.AuthMiddleware)
+	// Initialize the logger for logging middleware.
+	logger, err := newLogger(cfg.Log)
+	if err != nil {
+		fmt.Println("Error initializing log file:", err)
+		return
+	}
 
-	// Define routes and corresponding handlers.
-	r.HandleFunc("/add", handlers.AddEntryHandler(d)).Methods("POST")
-	r.HandleFunc("/get/{word}", handlers.GetDefinitionHandler(d)).Methods("GET")
-	r.HandleFunc("/remove/{word}", handlers.RemoveEntryHandler(d)).Methods("DELETE")
-	r.HandleFunc("/list", handlers.ListWordsHandler(d)).Methods("GET")
+	// Invalidate cached lookups on changes made through other servers.
+	if cfg.Dictionary.Cache.Watch {
+		go d.WatchChanges(context.Background(), func(err error) {
+			logger.Logger.Printf("dictionary cache: %v", err)
+		})
+	}
+
+	// Create the router serving every route group.
+	r := newRouter(server{
+		config:        cfg,
+		dictionary:    d,
+		keys:          keys,
+		authenticator: authenticator,
+		logger:        logger,
+		quotas:        quotas,
+		metrics:       m,
+	})
 
 	// Set up the HTTP server with the Gorilla Mux router.
-	http.Handle("/", r)
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           r,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+	}
 
-	// Start the server on port 8080.
-	fmt.Println("Server is running on port 8080...")
-	err = http.ListenAndServe(":8080", nil)
-	if err != nil {
-		fmt.Println("Error starting the server:", err)
+	// Serve HTTPS when a certificate is configured, reloading it when it changes.
+	if cfg.Server.TLS.CertFile != "" {
+		srv.TLSConfig, err = newTLSConfig(cfg.Server.TLS)
+		if err != nil {
+			fmt.Println("Error initializing TLS:", err)
+			os.Exit(2)
+		}
+	}
+
+	// Serve the metrics on their own listener when one is configured.
+	servers := []*http.Server{srv}
+	if cfg.Server.MetricsAddr != "" {
+		servers = append(servers, &http.Server{
+			Addr:              cfg.Server.MetricsAddr,
+			Handler:           newMetricsRouter(server{config: cfg, metrics: m}),
+			ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+			ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+			WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+			IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+		})
+		fmt.Printf("Metrics are served on %s%s...\n", cfg.Server.MetricsAddr, cfg.Server.MetricsPath)
+	}
+
+	// Serve until SIGINT or SIGTERM, then shut down gracefully.
+	fmt.Printf("Server is running on %s...\n", cfg.Server.Addr)
+	if err := serve(servers, time.Duration(cfg.Server.ShutdownTimeout), logger, d); err != nil {
+		fmt.Println("Error running the server:", err)
 		os.Exit(1)
 	}
 }
+
+// serve runs servers until one fails or the process receives SIGINT or SIGTERM.
+// It then stops accepting connections, drains in-flight requests for up to
+// timeout, and closes the logger and the dictionary.
+func serve(servers []*http.Server, timeout time.Duration, logger *middleware.Logger, d *dictionary.Dictionary) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			if srv.TLSConfig != nil {
+				errc <- srv.ListenAndServeTLS("", "")
+				return
+			}
+			errc <- srv.ListenAndServe()
+		}(srv)
+	}
+
+	var serveErr error
+	select {
+	case serveErr = <-errc:
+	case <-ctx.Done():
+		// A second signal kills the process instead of waiting for the drain.
+		stop()
+		fmt.Println("Shutting down...")
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	errs := []error{serveErr}
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("draining requests on %s: %w", srv.Addr, err))
+			srv.Close()
+		}
+	}
+	if err := logger.Close(); err != nil {
+		errs = append(errs, fmt.Errorf("closing log file: %w", err))
+	}
+	if err := d.Close(shutdownCtx); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// newValidator creates the entry validator described by the configuration.
+func newValidator(cfg config.ValidationConfig) (*middleware.Validator, error) {
+	v, err := middleware.NewValidator(cfg.Language)
+	if err != nil {
+		return nil, fmt.Errorf("validation.language: %v", err)
+	}
+
+	v.MinWordLength = cfg.MinWordLength
+	v.MaxWordLength = cfg.MaxWordLength
+	v.MinDefinitionLength = cfg.MinDefinitionLength
+	v.MaxDefinitionLength = cfg.MaxDefinitionLength
+	return v, nil
+}
+
+// newTLSConfig loads the server certificate, watches it for changes, and sets up
+// the verification of client certificates.
+func newTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	reloader, err := tlsutil.NewCertReloader(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	go reloader.Watch(context.Background(), time.Duration(cfg.ReloadInterval))
+
+	return tlsutil.ServerConfig(reloader, tlsutil.Options{ClientCAFile: cfg.ClientCAFile, ClientAuth: cfg.ClientAuth})
+}
+
+// newAuthenticator returns the authenticator selected by the auth mode:
+// the API key store, or a JWT verifier using local JWKS, PEM or HMAC keys.
+func newAuthenticator(cfg config.AuthConfig, keys *auth.KeyManager) (auth.Authenticator, error) {
+	if cfg.Mode != config.AuthModeJWT {
+		return keys, nil
+	}
+
+	var jwtKeys []auth.JWTKey
+	if cfg.JWT.JWKSFile != "" {
+		loaded, err := auth.LoadJWKS(cfg.JWT.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		jwtKeys = append(jwtKeys, loaded...)
+	}
+	if cfg.JWT.PEMFile != "" {
+		key, err := auth.LoadPEMKey(cfg.JWT.PEMFile)
+		if err != nil {
+			return nil, err
+		}
+		jwtKeys = append(jwtKeys, key)
+	}
+	if cfg.JWT.HMACSecret != "" {
+		jwtKeys = append(jwtKeys, auth.JWTKey{Key: []byte(cfg.JWT.HMACSecret)})
+	}
+
+	return auth.NewJWTVerifier(jwtKeys, auth.JWTOptions{
+		Issuer:      cfg.JWT.Issuer,
+		Audience:    cfg.JWT.Audience,
+		Leeway:      time.Duration(cfg.JWT.Leeway),
+		RolesClaim:  cfg.JWT.RolesClaim,
+		RoleMapping: cfg.JWT.RoleMapping,
+	})
+}
+
+// newQuotaStore returns the MongoDB quota store when daily quotas are enabled.
+func newQuotaStore(cfg config.Config, d *dictionary.Dictionary) (middleware.QuotaStore, error) {
+	if cfg.RateLimit.DailyQuota == 0 {
+		return middleware.NewMemoryQuotaStore(), nil
+	}
+
+	ctx := context.Background()
+	if timeout := time.Duration(cfg.Dictionary.Timeouts.Connect); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return middleware.NewMongoQuotaStore(ctx, d.Database().Collection(cfg.RateLimit.QuotasCollection))
+}
+
+// dictionaryOptions converts the dictionary configuration to dictionary.Options.
+func dictionaryOptions(cfg config.DictionaryConfig) dictionary.Options {
+	return dictionary.Options{
+		StripAccents: cfg.StripAccents,
+		Timeouts: dictionary.Timeouts{
+			Connect: time.Duration(cfg.Timeouts.Connect),
+			Add:     time.Duration(cfg.Timeouts.Add),
+			Get:     time.Duration(cfg.Timeouts.Get),
+			Remove:  time.Duration(cfg.Timeouts.Remove),
+			List:    time.Duration(cfg.Timeouts.List),
+		},
+		Cache: dictionary.CacheOptions{
+			Size:        cfg.Cache.Size,
+			TTL:         time.Duration(cfg.Cache.TTL),
+			NegativeTTL: time.Duration(cfg.Cache.NegativeTTL),
+		},
+	}
+}
+
+// newLogger opens the access log with the configured format and level.
+func newLogger(cfg config.LogConfig) (*middleware.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		return nil, err
+	}
+
+	logger, err := middleware.NewLoggerWithOptions(cfg.File, middleware.LoggerOptions{
+		Format: cfg.Format,
+		Level:  level,
+		Rotation: middleware.RotationOptions{
+			MaxSize:    cfg.MaxSizeMB << 20,
+			Interval:   time.Duration(cfg.RotateEvery),
+			MaxAge:     time.Duration(cfg.MaxAge),
+			MaxBackups: cfg.MaxBackups,
+			Compress:   cfg.Compress,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Reopen the log file on SIGHUP, after an external logrotate moved it away.
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := logger.Reopen(); err != nil {
+				fmt.Println("Error reopening log file:", err)
+			}
+		}
+	}()
+	return logger, nil
+}
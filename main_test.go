@@ -23,6 +23,7 @@ func TestAddWordHandler(t *testing.T) {
 	if err != nil {
 		t.Fatal("Error creating dictionary:", err)
 	}
+	d.Remove("test_word")
 	logger, err := middleware.NewLogger("test_log.txt")
 	if err != nil {
 		t.Fatal("Error creating logger:", err)
@@ -43,6 +44,7 @@ func TestAddWordHandler(t *testing.T) {
 	if err != nil {
 		t.Fatal("Error creating request:", err)
 	}
+	req.Header.Set("Content-Type", "application/json")
 
 	// 3. Create a handler using AddEntryHandler.
 	handler := handlers.AddEntryHandler(d)
@@ -98,7 +100,7 @@ func TestGetDefinitionHandler(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code, "Status code should be OK")
 
 	// 7. Verify the response body using JSONEq.
-	expectedResponse := `{"word":"test_word","definition":"test_definition"}`
+	expectedResponse := `{"word":"test_word","definition":"test_definition","match":"exact","matched_word":"test_word"}`
 	assert.JSONEq(t, expectedResponse, w.Body.String(), "Response body should match expected response")
 }
 
@@ -137,12 +139,15 @@ func TestRemoveEntryHandler(t *testing.T) {
 	r.ServeHTTP(w, req)
 
 	// 6. Verify the response status code.
-	assert.Equal(t, http.StatusOK, w.Code, "Status code should be OK")
+	assert.Equal(t, http.StatusNoContent, w.Code, "Status code should be No Content")
 
-	// 7. Verify the response body or any other expectations based on your implementation.
-	// Example: Check if the response contains a JSON message.
-	expectedResponse := `{"message":"Word 'test_word' removed successfully"}`
-	assert.Contains(t, w.Body.String(), expectedResponse, "Response body should contain expected response")
+	// 7. Verify that the response has no body.
+	assert.Empty(t, w.Body.String(), "Response body should be empty")
+
+	// 8. Removing the word again answers Not Found.
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "Status code should be Not Found")
 }
 
 // TestListWordsHandler tests the ListWordsHandler function.
@@ -191,3 +196,116 @@ func TestListWordsHandler(t *testing.T) {
 		assert.Contains(t, actualResponse, word, "Response body should contain expected word")
 	}
 }
+
+// TestConditionalRequests tests ETags, 304 responses and If-Match preconditions.
+func TestConditionalRequests(t *testing.T) {
+	// 1. Create a new dictionary and a router over the word resource, without the word.
+	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "testCollection")
+	if err != nil {
+		t.Fatal("Error creating dictionary:", err)
+	}
+	d.Remove("etag_word")
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/words/{word}", handlers.GetDefinitionHandler(d)).Methods("GET")
+	r.HandleFunc("/v1/words/{word}", handlers.PutWordHandler(d)).Methods("PUT")
+	r.HandleFunc("/v1/words/{word}", handlers.PatchWordHandler(d)).Methods("PATCH")
+	r.HandleFunc("/v1/words/{word}", handlers.RemoveEntryHandler(d)).Methods("DELETE")
+	serve := func(method, body string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/v1/words/etag_word", strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 2. Creating the word returns its ETag, which GET repeats.
+	w := serve("PUT", `{"definition":"first definition"}`, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"word":"etag_word","definition":"first definition","match":"exact","matched_word":"etag_word"}`, w.Body.String(),
+		"the version is only exposed through the ETag")
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = serve("GET", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, etag, w.Header().Get("ETag"))
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+
+	// 3. A GET with the current ETag is answered with 304.
+	w = serve("GET", "", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// 4. A change with the current ETag succeeds and returns a new ETag.
+	w = serve("PATCH", `{"definition":"second definition"}`, http.Header{"If-Match": {etag}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotEqual(t, etag, w.Header().Get("ETag"))
+
+	// 5. Changes with the stale ETag fail with 412.
+	w = serve("PUT", `{"definition":"third definition"}`, http.Header{"If-Match": {etag}})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	w = serve("DELETE", "", http.Header{"If-Match": {etag}})
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	// 6. The stale ETag no longer answers 304.
+	w = serve("GET", "", http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "second definition")
+}
+
+// TestConditionalListing tests the validators of the word list and its 304 responses.
+func TestConditionalListing(t *testing.T) {
+	// 1. Create a new dictionary and a router over the list, without the word.
+	d, err := dictionary.NewDictionary("mongodb://localhost:27017", "testDB", "testCollection")
+	if err != nil {
+		t.Fatal("Error creating dictionary:", err)
+	}
+	d.Remove("list_etag_word")
+
+	r := mux.NewRouter()
+	r.HandleFunc("/v1/words", handlers.ListWordsHandler(d)).Methods("GET")
+	serve := func(header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/v1/words", nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// 2. The list carries an ETag and, with If-None-Match or If-Modified-Since, answers 304.
+	w := serve(nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	w = serve(http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+	assert.Empty(t, w.Body.String())
+
+	// 3. Adding a word changes the ETag and the last modification time.
+	_, err = d.Create("list_etag_word", "definition")
+	assert.NoError(t, err)
+	w = serve(http.Header{"If-None-Match": {etag}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "list_etag_word")
+	added := w.Header().Get("ETag")
+	assert.NotEqual(t, etag, added)
+
+	w = serve(http.Header{"If-Modified-Since": {w.Header().Get("Last-Modified")}})
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	// 4. Removing it changes the ETag again.
+	_, err = d.Remove("list_etag_word")
+	assert.NoError(t, err)
+	w = serve(http.Header{"If-None-Match": {added}})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "list_etag_word")
+}
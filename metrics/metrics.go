@@ -0,0 +1,180 @@
+// metrics/metrics.go
+package metrics
+
+import (
+	"context"
+	"errors"
+	"estiam/dictionary"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of latency histograms.
+var DefaultBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Metrics holds the metrics of the dictionary server, with the Go runtime and
+// process metrics. The lookup hit ratio is dictionary_lookups_total{result="hit"}
+// over the sum of both results; it counts the lookups that reach MongoDB, past the cache.
+type Metrics struct {
+	Registry *prometheus.Registry
+
+	Requests        *prometheus.CounterVec
+	RequestDuration *prometheus.HistogramVec
+	InFlight        prometheus.Gauge
+
+	Lookups           *prometheus.CounterVec
+	OperationDuration *prometheus.HistogramVec
+	OperationErrors   *prometheus.CounterVec
+}
+
+// New creates the metrics of the server in a new registry.
+func New() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "HTTP requests by method, route template and status code.",
+		}, []string{"method", "route", "status"}),
+		RequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "HTTP request latencies by method, route template and status code.",
+			Buckets: DefaultBuckets,
+		}, []string{"method", "route", "status"}),
+		InFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "http_requests_in_flight",
+			Help: "HTTP requests being served.",
+		}),
+
+		Lookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dictionary_lookups_total",
+			Help: "Word lookups by result: hit or miss.",
+		}, []string{"result"}),
+		OperationDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "dictionary_operation_duration_seconds",
+			Help:    "MongoDB dictionary operation latencies by operation.",
+			Buckets: DefaultBuckets,
+		}, []string{"operation"}),
+		OperationErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "dictionary_operation_errors_total",
+			Help: "Failed MongoDB dictionary operations by operation and error: timeout, unavailable, canceled or other.",
+		}, []string{"operation", "error"}),
+	}
+
+	m.Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.Requests, m.RequestDuration, m.InFlight,
+		m.Lookups, m.OperationDuration, m.OperationErrors,
+	)
+
+	// Expose both lookup results from the start, so that ratios are defined.
+	m.Lookups.WithLabelValues("hit")
+	m.Lookups.WithLabelValues("miss")
+	return m
+}
+
+// Handler serves the metrics to Prometheus scrapers.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
+
+// ObserveRequest records a served HTTP request.
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	code := strconv.Itoa(status)
+	m.Requests.WithLabelValues(method, route, code).Inc()
+	m.RequestDuration.WithLabelValues(method, route, code).Observe(duration.Seconds())
+}
+
+// ObserveDictionary records a dictionary operation. It is a dictionary.Observer.
+func (m *Metrics) ObserveDictionary(op string, duration time.Duration, err error) {
+	m.OperationDuration.WithLabelValues(op).Observe(duration.Seconds())
+
+	if op == "lookup" {
+		switch {
+		case err == nil:
+			m.Lookups.WithLabelValues("hit").Inc()
+		case errors.Is(err, dictionary.ErrNotFound):
+			m.Lookups.WithLabelValues("miss").Inc()
+		}
+	}
+
+	if err != nil && !errors.Is(err, dictionary.ErrNotFound) {
+		m.OperationErrors.WithLabelValues(op, errorKind(err)).Inc()
+	}
+}
+
+// RegisterDictionarySize exposes the number of dictionary entries, counted by
+// count at each scrape. The gauge is omitted when counting fails.
+func (m *Metrics) RegisterDictionarySize(count func(ctx context.Context) (int64, error)) {
+	m.Registry.MustRegister(&sizeCollector{
+		desc:  prometheus.NewDesc("dictionary_entries", "Entries in the dictionary.", nil, nil),
+		count: count,
+	})
+}
+
+// sizeCollector collects the dictionary size, when it can be counted.
+type sizeCollector struct {
+	desc  *prometheus.Desc
+	count func(ctx context.Context) (int64, error)
+}
+
+// Describe sends the description of the dictionary size.
+func (c *sizeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect counts the dictionary entries, and sends their number unless counting fails.
+func (c *sizeCollector) Collect(ch chan<- prometheus.Metric) {
+	n, err := c.count(context.Background())
+	if err != nil {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(n))
+}
+
+// RegisterDictionaryCache exposes the statistics of the lookup cache, read from
+// stats at each scrape. The hit ratio is dictionary_cache_hits_total over the sum
+// of hits and misses.
+func (m *Metrics) RegisterDictionaryCache(stats func() dictionary.CacheStats) {
+	m.Registry.MustRegister(
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "dictionary_cache_hits_total",
+			Help: "Lookups served by the cache.",
+		}, func() float64 { return float64(stats().Hits) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "dictionary_cache_misses_total",
+			Help: "Lookups not found in the cache, which reach MongoDB.",
+		}, func() float64 { return float64(stats().Misses) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "dictionary_cache_evictions_total",
+			Help: "Cached lookups evicted to make room.",
+		}, func() float64 { return float64(stats().Evictions) }),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "dictionary_cache_invalidations_total",
+			Help: "Cached lookups dropped after changes.",
+		}, func() float64 { return float64(stats().Invalidations) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "dictionary_cache_entries",
+			Help: "Lookups in the cache.",
+		}, func() float64 { return float64(stats().Entries) }),
+	)
+}
+
+// errorKind classifies a dictionary error for the error label.
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, dictionary.ErrTimeout):
+		return "timeout"
+	case errors.Is(err, dictionary.ErrUnavailable):
+		return "unavailable"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return "other"
+}
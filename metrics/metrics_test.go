@@ -0,0 +1,90 @@
+// metrics/metrics_test.go
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"estiam/dictionary"
+	"estiam/metrics"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandler(t *testing.T) {
+	// 1. Scrape the metrics of a server that has not served any request yet.
+	m := metrics.New()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	// 2. The runtime, the process and the in-flight requests are already exposed.
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, body, "\ngo_goroutines ")
+	assert.Contains(t, body, "\nprocess_cpu_seconds_total ")
+	assert.Contains(t, body, "# TYPE http_requests_in_flight gauge\nhttp_requests_in_flight 0\n")
+	assert.Contains(t, body, `dictionary_lookups_total{result="miss"} 0`)
+
+	// 3. Requests are counted by method, route template and status.
+	m.ObserveRequest("GET", "/get/{word}", http.StatusNotFound, 50*time.Millisecond)
+	w = httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body = w.Body.String()
+	assert.Contains(t, body, `http_requests_total{method="GET",route="/get/{word}",status="404"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_bucket{method="GET",route="/get/{word}",status="404",le="0.05"} 1`)
+	assert.Contains(t, body, `http_request_duration_seconds_count{method="GET",route="/get/{word}",status="404"} 1`)
+}
+
+func TestObserveDictionary(t *testing.T) {
+	// 1. Record lookups and failed operations.
+	m := metrics.New()
+	m.ObserveDictionary("lookup", time.Millisecond, nil)
+	m.ObserveDictionary("lookup", time.Millisecond, fmt.Errorf("%w: chat", dictionary.ErrNotFound))
+	m.ObserveDictionary("add", time.Second, fmt.Errorf("error adding word: %w", dictionary.ErrTimeout))
+
+	// 2. Hits, misses, latencies and errors are counted; misses are not errors.
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Lookups.WithLabelValues("hit")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Lookups.WithLabelValues("miss")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.OperationDuration))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.OperationErrors.WithLabelValues("lookup", "other")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.OperationErrors.WithLabelValues("add", "timeout")))
+
+	// 3. The dictionary size is omitted while it cannot be counted.
+	size := int64(0)
+	var err error
+	m.RegisterDictionarySize(func(ctx context.Context) (int64, error) { return size, err })
+
+	size = 42
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "\ndictionary_entries 42\n")
+
+	err = errors.New("down")
+	w = httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.NotContains(t, w.Body.String(), "dictionary_entries")
+}
+
+func TestRegisterDictionaryCache(t *testing.T) {
+	// 1. Expose cache statistics.
+	m := metrics.New()
+	stats := dictionary.CacheStats{Hits: 9, Misses: 3, Evictions: 1, Entries: 2}
+	m.RegisterDictionaryCache(func() dictionary.CacheStats { return stats })
+
+	// 2. They are read at each scrape.
+	stats.Hits++
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	assert.Contains(t, body, "# TYPE dictionary_cache_hits_total counter\ndictionary_cache_hits_total 10\n")
+	assert.Contains(t, body, "\ndictionary_cache_misses_total 3\n")
+	assert.Contains(t, body, "\ndictionary_cache_evictions_total 1\n")
+	assert.Contains(t, body, "\ndictionary_cache_invalidations_total 0\n")
+	assert.Contains(t, body, "\ndictionary_cache_entries 2\n")
+}
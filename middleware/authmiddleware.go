@@ -2,26 +2,84 @@
 package middleware
 
 import (
+	"errors"
+	"estiam/auth"
+	"fmt"
+	"log"
 	"net/http"
+
+	"github.com/gorilla/mux"
 )
 
-// validToken is the valid authentication token for the middleware.
-const validToken = "Bearer hX6FfKOrKHlY7h1rYFxzdtgR7yzzsV0QVmtkH33aDDM="
-
-// AuthMiddleware is a middleware function that checks the Authorization header for a valid token.
-// If the token is not valid, it responds with an Unauthorized status.
-func AuthMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Get the token from the Authorization header.
-		token := r.Header.Get("Authorization")
-
-		// Check if the token is valid.
-		if token != validToken {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		// If the token is valid, proceed to the next handler.
-		next.ServeHTTP(w, r)
-	})
+// NewAuthMiddleware returns a middleware that authenticates every request with a.
+// The caller's identity is stored in the request context. Rejected credentials are
+// answered with an Unauthorized status; when they cannot be checked, e.g. during a
+// key store outage, the request is answered with Service Unavailable and the error
+// is logged.
+func NewAuthMiddleware(a auth.Authenticator) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Authenticate the credentials carried by the request.
+			id, err := a.Authenticate(r)
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				if !errors.Is(err, auth.ErrNoCredentials) {
+					recordAuthRejection(r.Context())
+				}
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				HandleError(w, "missing or invalid credentials", http.StatusUnauthorized)
+				return
+			}
+			if err != nil {
+				LogError(r.Context(), fmt.Errorf("authenticating: %w", err))
+				w.Header().Set("Retry-After", "5")
+				HandleError(w, "credentials cannot be checked right now", http.StatusServiceUnavailable)
+				return
+			}
+
+			// If the credentials are valid, proceed to the next handler.
+			setLogIdentity(r.Context(), id)
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), id)))
+		})
+	}
+}
+
+// NewOptionalAuthMiddleware is like NewAuthMiddleware, but requests without an
+// Authorization header or a client certificate proceed as auth.AnonymousIdentity.
+// Requests with invalid credentials are still rejected, so that a typo in a token
+// is not silently ignored.
+func NewOptionalAuthMiddleware(a auth.Authenticator) mux.MiddlewareFunc {
+	authenticate := NewAuthMiddleware(a)
+
+	return func(next http.Handler) http.Handler {
+		authenticated := authenticate(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "" || auth.HasClientCert(r) {
+				authenticated.ServeHTTP(w, r)
+				return
+			}
+
+			setLogIdentity(r.Context(), auth.AnonymousIdentity)
+			next.ServeHTTP(w, r.WithContext(auth.WithIdentity(r.Context(), auth.AnonymousIdentity)))
+		})
+	}
+}
+
+// RequireScope returns a middleware that lets a request through only when the
+// identity set by the auth middleware is granted scope. Other requests are answered
+// with a Forbidden status, and the denial is recorded in audit.
+func RequireScope(scope auth.Scope, audit *log.Logger) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := auth.IdentityFromContext(r.Context())
+			if !ok || !id.Has(scope) {
+				audit.Printf("access denied: subject=%q scope=%s %s %s request_id=%s\n",
+					id.Subject, scope, r.Method, r.URL.RequestURI(), RequestIDFromContext(r.Context()))
+				HandleError(w, fmt.Sprintf("the %q scope is required", scope), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,72 @@
+// middleware/cache.go
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// CacheControl sets the Cache-Control header of successful and not-modified
+// responses, letting clients reuse them for maxAge; shared caches may only store
+// them when public is set. Errors are never cached.
+func CacheControl(maxAge time.Duration, public bool) mux.MiddlewareFunc {
+	visibility := "private"
+	if public {
+		visibility = "public"
+	}
+	directives := fmt.Sprintf("%s, max-age=%d", visibility, int(maxAge.Seconds()))
+	if maxAge <= 0 {
+		directives = visibility + ", no-cache"
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&cacheControlWriter{ResponseWriter: w, directives: directives}, r)
+		})
+	}
+}
+
+// cacheControlWriter sets Cache-Control when the status code is written.
+type cacheControlWriter struct {
+	http.ResponseWriter
+	directives  string
+	wroteHeader bool
+}
+
+// WriteHeader sets Cache-Control for 200 and 304 responses, and forwards the status.
+func (cw *cacheControlWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+		header := cw.Header()
+		if (status == http.StatusOK || status == http.StatusNotModified) && header.Get("Cache-Control") == "" {
+			header.Set("Cache-Control", cw.directives)
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+// Write implies a 200 status when none was written.
+func (cw *cacheControlWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush forwards flushes to streaming-capable writers.
+func (cw *cacheControlWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (cw *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
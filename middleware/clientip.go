@@ -0,0 +1,91 @@
+// middleware/clientip.go
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type clientIPKey struct{}
+
+// NewClientIPMiddleware returns a middleware resolving the IP address of the
+// client of each request, as used by rate limits and the combined access log.
+// It is the address of the connection, unless that is one of the trusted
+// proxies, given as IP addresses or CIDR prefixes: the client is then the
+// rightmost address of X-Forwarded-For that is not a trusted proxy itself.
+// Entries that are neither are ignored; config.Validate rejects them.
+func NewClientIPMiddleware(trustedProxies []string) mux.MiddlewareFunc {
+	var trusted []netip.Prefix
+	for _, proxy := range trustedProxies {
+		if prefix, err := ParseProxy(proxy); err == nil {
+			trusted = append(trusted, prefix)
+		}
+	}
+	isTrusted := func(addr netip.Addr) bool {
+		for _, prefix := range trusted {
+			if prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := remoteIP(r)
+			addr, err := netip.ParseAddr(ip)
+			if err == nil && isTrusted(addr) {
+				hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+				for i := len(hops) - 1; i >= 0; i-- {
+					hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+					if err != nil {
+						break
+					}
+					ip = hop.Unmap().String()
+					if !isTrusted(hop) {
+						break
+					}
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// ParseProxy parses a trusted proxy given as an IP address or a CIDR prefix.
+func ParseProxy(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// clientIP returns the IP address of the client of r, as resolved by the
+// client IP middleware, or else the address of the connection.
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// remoteIP returns the IP address of the connection of r.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,312 @@
+// middleware/compress.go
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings, in order of preference.
+const (
+	EncodingZstd = "zstd"
+	EncodingGzip = "gzip"
+)
+
+// incompressibleTypes lists the media types that are compressed already;
+// "image/", "audio/" and "video/" match every subtype.
+var incompressibleTypes = []string{
+	"image/", "audio/", "video/", "font/woff", "font/woff2",
+	"application/gzip", "application/x-gzip", "application/zstd", "application/zip",
+	"application/x-7z-compressed", "application/x-bzip2", "application/x-xz", "application/pdf",
+}
+
+var (
+	gzipWriters = sync.Pool{New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	}}
+	zstdWriters = sync.Pool{New: func() interface{} {
+		return newZstdWriter()
+	}}
+)
+
+// zstdOptions suit many small responses encoded concurrently, one goroutine each.
+var zstdOptions = []zstd.EOption{zstd.WithEncoderConcurrency(1), zstd.WithLowerEncoderMem(true)}
+
+// The first encoder is built at startup, so that invalid options fail there
+// rather than on the first compressed response.
+func init() {
+	zstdWriters.Put(newZstdWriter())
+}
+
+// newZstdWriter creates a zstd encoder with zstdOptions. The options are fixed,
+// so an error is a programming error and panics.
+func newZstdWriter() *zstd.Encoder {
+	w, err := zstd.NewWriter(io.Discard, zstdOptions...)
+	if err != nil {
+		panic(fmt.Sprintf("creating zstd encoder: %v", err))
+	}
+	return w
+}
+
+// NewCompressionMiddleware compresses responses with zstd or gzip, as negotiated
+// by Accept-Encoding. Responses smaller than minSize bytes, responses with a
+// Content-Encoding or Content-Range, and already compressed media types are sent
+// as is. Flushed responses are compressed as they stream, whatever their size.
+//
+// Strong ETags of compressed responses get the coding as a suffix, e.g.
+// "65a1f0c2e4b0a1b2c3d4e5f6-3-gzip", and the suffix is removed from the ETags of
+// If-None-Match and If-Match before the handler sees them.
+func NewCompressionMiddleware(minSize int) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			encoding := negotiateEncoding(r.Header.Values("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			// A 304 answers with the ETag of the representation the client holds.
+			suffixed := strings.Contains(strings.Join(r.Header.Values("If-None-Match"), ","), "-"+encoding+`"`)
+			for _, name := range []string{"If-None-Match", "If-Match"} {
+				if values := r.Header.Values(name); len(values) > 0 {
+					r.Header.Set(name, stripEncodingSuffix(strings.Join(values, ",")))
+				}
+			}
+
+			cw := &compressWriter{ResponseWriter: w, encoding: encoding, minSize: minSize, suffixed: suffixed}
+			defer cw.Close()
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the preferred supported coding of Accept-Encoding
+// headers, or "" when identity should be sent.
+func negotiateEncoding(headers []string) string {
+	qualities := map[string]float64{}
+	for _, header := range headers {
+		for _, part := range strings.Split(header, ",") {
+			params := strings.Split(part, ";")
+			name := strings.ToLower(strings.TrimSpace(params[0]))
+			q := 1.0
+			for _, param := range params[1:] {
+				if key, value, _ := strings.Cut(strings.TrimSpace(param), "="); strings.EqualFold(key, "q") {
+					if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+						q = parsed
+					}
+				}
+			}
+			if _, seen := qualities[name]; name != "" && !seen {
+				qualities[name] = q
+			}
+		}
+	}
+
+	best, bestQuality := "", 0.0
+	for _, encoding := range []string{EncodingZstd, EncodingGzip} {
+		q, ok := qualities[encoding]
+		if !ok {
+			q = qualities["*"]
+		}
+		if q > bestQuality {
+			best, bestQuality = encoding, q
+		}
+	}
+	return best
+}
+
+// stripEncodingSuffix removes the coding suffixes added to ETags by the middleware.
+func stripEncodingSuffix(tags string) string {
+	for _, encoding := range []string{EncodingZstd, EncodingGzip} {
+		tags = strings.ReplaceAll(tags, "-"+encoding+`"`, `"`)
+	}
+	return tags
+}
+
+// compressWriter buffers the start of a response until it knows whether to
+// compress it: once minSize bytes are written, the response is flushed, or the
+// handler returns.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	// suffixed is set when If-None-Match held a compressed representation's ETag.
+	suffixed bool
+
+	status  int
+	buf     bytes.Buffer
+	decided bool
+	encoder io.WriteCloser
+}
+
+// WriteHeader delays the status until the coding is decided.
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.status != 0 || cw.decided {
+		return
+	}
+	if status < 200 {
+		// Informational responses, such as 103 Early Hints, go out right away.
+		cw.ResponseWriter.WriteHeader(status)
+		return
+	}
+	cw.status = status
+}
+
+// Write buffers b until the coding is decided, then writes it through the encoder, if any.
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	if !cw.decided {
+		cw.buf.Write(b)
+		if cw.buf.Len() < cw.minSize {
+			return len(b), nil
+		}
+		if err := cw.decide(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends what was written so far, deciding the coding as for a large response.
+func (cw *compressWriter) Flush() {
+	if !cw.decided {
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.decide(true)
+	}
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close decides the coding of a response that ended below the threshold, and
+// finishes the compressed stream.
+func (cw *compressWriter) Close() error {
+	if !cw.decided {
+		if cw.status == 0 {
+			// The handler wrote nothing; let the server send its default response.
+			return nil
+		}
+		if err := cw.decide(cw.buf.Len() >= cw.minSize); err != nil {
+			return err
+		}
+	}
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	switch encoder := cw.encoder.(type) {
+	case *gzip.Writer:
+		gzipWriters.Put(encoder)
+	case *zstd.Encoder:
+		zstdWriters.Put(encoder)
+	}
+	cw.encoder = nil
+	return err
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// decide writes the headers, compressing the response when large is set and the
+// response can be compressed, then writes the buffered body.
+func (cw *compressWriter) decide(large bool) error {
+	cw.decided = true
+	header := cw.Header()
+
+	if large && cw.compressible() {
+		if header.Get("Content-Type") == "" {
+			// Sniff the type from the plain body, as the server would.
+			header.Set("Content-Type", http.DetectContentType(cw.buf.Bytes()))
+		}
+		header.Set("Content-Encoding", cw.encoding)
+		header.Del("Content-Length")
+		cw.suffixETag()
+
+		switch cw.encoding {
+		case EncodingZstd:
+			encoder := zstdWriters.Get().(*zstd.Encoder)
+			encoder.Reset(cw.ResponseWriter)
+			cw.encoder = encoder
+		default:
+			encoder := gzipWriters.Get().(*gzip.Writer)
+			encoder.Reset(cw.ResponseWriter)
+			cw.encoder = encoder
+		}
+	}
+
+	if cw.status == http.StatusNotModified && cw.suffixed {
+		cw.suffixETag()
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.status)
+	if cw.buf.Len() == 0 {
+		return nil
+	}
+
+	var err error
+	if cw.encoder != nil {
+		_, err = cw.encoder.Write(cw.buf.Bytes())
+	} else {
+		_, err = cw.ResponseWriter.Write(cw.buf.Bytes())
+	}
+	cw.buf = bytes.Buffer{}
+	return err
+}
+
+// suffixETag appends the coding to a strong ETag.
+func (cw *compressWriter) suffixETag() {
+	if etag := cw.Header().Get("ETag"); strings.HasPrefix(etag, `"`) {
+		cw.Header().Set("ETag", strings.TrimSuffix(etag, `"`)+"-"+cw.encoding+`"`)
+	}
+}
+
+// compressible reports whether the status, headers and media type of the response allow compressing it.
+func (cw *compressWriter) compressible() bool {
+	if cw.status == http.StatusNoContent || cw.status == http.StatusNotModified || cw.status == http.StatusPartialContent {
+		return false
+	}
+	header := cw.Header()
+	if header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" {
+		return false
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(cw.buf.Bytes())
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return true
+	}
+	for _, t := range incompressibleTypes {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+// middleware/deprecation.go
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Deprecated marks the responses of a route as deprecated since the given time, with
+// the Deprecation (RFC 9745) and Sunset (RFC 8594) headers, and links to its successor.
+// Variables of the route, such as {word}, are filled in the successor path.
+func Deprecated(since, sunset time.Time, successor string) mux.MiddlewareFunc {
+	deprecation := fmt.Sprintf("@%d", since.Unix())
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path := successor
+			for name, value := range mux.Vars(r) {
+				path = strings.ReplaceAll(path, "{"+name+"}", url.PathEscape(value))
+			}
+
+			w.Header().Set("Deprecation", deprecation)
+			w.Header().Set("Sunset", sunsetDate)
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, path))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
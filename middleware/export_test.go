@@ -0,0 +1,9 @@
+// export_test.go
+package middleware
+
+// SetRename replaces the function renaming the file of f on rotation, to make it fail.
+func SetRename(f *RotatingFile, rename func(oldpath, newpath string) error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rename = rename
+}
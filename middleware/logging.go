@@ -2,64 +2,291 @@
 package middleware
 
 import (
+	"context"
+	"estiam/auth"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
-	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
+// Access log formats.
+const (
+	LogFormatJSON     = "json"
+	LogFormatLogfmt   = "logfmt"
+	LogFormatCombined = "combined"
+)
+
+// LoggerOptions configures the format and level of the access log.
+type LoggerOptions struct {
+	// Format is "json" (the default), "logfmt" or "combined" (Apache combined log format).
+	Format string
+	// Level is the minimum level written: successful requests are logged at Info,
+	// 4xx responses at Warn and 5xx responses at Error.
+	Level slog.Level
+	// Rotation configures the rotation and retention of the log file.
+	Rotation RotationOptions
+}
+
 // Logger struct encapsulates the log-related functionality.
 type Logger struct {
-	LogFile *os.File
-	Logger  *log.Logger
+	// Logger writes free-form records, such as access denials, to the log file.
+	Logger *log.Logger
+
+	options LoggerOptions
+	access  *slog.Logger
+
+	mu   sync.RWMutex
+	file *RotatingFile
 }
 
 // LoggingMiddlewareFunc is a function signature for the LoggingMiddleware.
 type LoggingMiddlewareFunc func(http.Handler) http.Handler
 
-// NewLogger initializes a new Logger instance.
+// NewLogger initializes a new Logger instance writing JSON records.
 func NewLogger(filename string) (*Logger, error) {
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
-	if err != nil {
+	return NewLoggerWithOptions(filename, LoggerOptions{})
+}
+
+// NewLoggerWithOptions initializes a new Logger instance with the given format and level.
+func NewLoggerWithOptions(filename string, opts LoggerOptions) (*Logger, error) {
+	switch opts.Format {
+	case "":
+		opts.Format = LogFormatJSON
+	case LogFormatJSON, LogFormatLogfmt, LogFormatCombined:
+	default:
+		return nil, fmt.Errorf("unsupported log format %q", opts.Format)
+	}
+
+	l := &Logger{options: opts}
+	if err := l.SetLogFile(filename); err != nil {
 		return nil, err
 	}
 
-	logger := log.New(file, "", log.LstdFlags)
+	// Records go through l so that they follow SetLogFile, rotations and reopens.
+	handlerOptions := &slog.HandlerOptions{Level: opts.Level}
+	var handler slog.Handler
+	switch opts.Format {
+	case LogFormatLogfmt:
+		handler = slog.NewTextHandler(l, handlerOptions)
+	default:
+		handler = slog.NewJSONHandler(l, handlerOptions)
+	}
+
+	l.access = slog.New(handler)
+	if opts.Format == LogFormatCombined {
+		l.Logger = log.New(l, "", log.LstdFlags)
+	} else {
+		l.Logger = slog.NewLogLogger(handler, slog.LevelWarn)
+	}
+	return l, nil
+}
 
-	return &Logger{LogFile: file, Logger: logger}, nil
+// Structured returns the structured logger writing to the log file, for
+// application records such as backend errors.
+func (l *Logger) Structured() *slog.Logger {
+	return l.access
 }
 
-// LoggingMiddleware adds logging functionality to HTTP requests.
+// LoggingMiddleware adds logging functionality to HTTP requests. Each request is
+// logged once it completes, with its status code, response size and latency.
 func (l *Logger) LoggingMiddleware() LoggingMiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+			info := &requestLogInfo{}
 
-			next.ServeHTTP(w, r)
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestLogInfoKey{}, info)))
 
-			l.Logger.Printf("[%s] %s %s %s\n", time.Since(start), r.Method, r.RequestURI, r.RemoteAddr)
+			l.logRequest(r, rec, info, start)
 		})
 	}
 }
 
-// SetLogFile creates or opens a log file for logging.
+// logRequest writes the access log record of a completed request.
+func (l *Logger) logRequest(r *http.Request, rec *statusRecorder, info *requestLogInfo, start time.Time) {
+	latency := time.Since(start)
+	status := rec.Status()
+
+	if l.options.Format == LogFormatCombined {
+		l.Write([]byte(combinedLogLine(r, status, rec.bytes, info.user, start)))
+		if info.err != nil {
+			l.Logger.Printf("%s %s: %v", r.Method, r.URL.RequestURI(), info.err)
+		}
+		return
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case status >= 500:
+		level = slog.LevelError
+	case status >= 400:
+		level = slog.LevelWarn
+	}
+
+	route := ""
+	if current := mux.CurrentRoute(r); current != nil {
+		route, _ = current.GetPathTemplate()
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", r.Method),
+		slog.String("uri", r.URL.RequestURI()),
+		slog.String("route", route),
+		slog.Int("status", status),
+		slog.Int64("bytes", rec.bytes),
+		slog.Float64("latency_ms", float64(latency.Microseconds())/1000),
+		slog.String("remote_addr", r.RemoteAddr),
+		slog.String("user", info.user),
+		slog.String("request_id", RequestIDFromContext(r.Context())),
+		slog.String("user_agent", r.UserAgent()),
+	}
+	if info.err != nil {
+		attrs = append(attrs, slog.String("error", info.err.Error()))
+	}
+	l.access.LogAttrs(r.Context(), level, "request", attrs...)
+}
+
+// combinedLogLine formats a request in the Apache combined log format.
+func combinedLogLine(r *http.Request, status int, bytes int64, user string, start time.Time) string {
+	size := "-"
+	if bytes > 0 {
+		size = fmt.Sprint(bytes)
+	}
+	if user == "" {
+		user = "-"
+	}
+
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s %q %q\n",
+		clientIP(r), strings.ReplaceAll(user, " ", "_"), start.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method, r.URL.RequestURI(), r.Proto, status, size, r.Referer(), r.UserAgent())
+}
+
+// SetLogFile creates or opens a log file for logging, replacing and closing the previous one.
 func (l *Logger) SetLogFile(filename string) error {
-	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	file, err := OpenRotatingFile(filename, l.options.Rotation)
 	if err != nil {
 		return err
 	}
 
-	l.LogFile = file
-	l.Logger.SetOutput(l.LogFile)
+	l.mu.Lock()
+	previous := l.file
+	l.file = file
+	l.mu.Unlock()
 
+	if previous != nil {
+		return previous.Close()
+	}
 	return nil
 }
 
+// Write appends p to the current log file.
+func (l *Logger) Write(p []byte) (int, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.file.Write(p)
+}
+
+// Rotate rotates the log file now, regardless of its size and age.
+func (l *Logger) Rotate() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.file.Rotate()
+}
+
+// Reopen reopens the log file at the same path, after an external tool such as
+// logrotate moved it away. main calls it on SIGHUP.
+func (l *Logger) Reopen() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.file.Reopen()
+}
+
+// Close closes the log file, waiting for rotated segments to be compressed.
+func (l *Logger) Close() error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.file.Close()
+}
+
 // MiddlewareFunc converts LoggingMiddlewareFunc to mux.MiddlewareFunc.
 func (l *Logger) MiddlewareFunc() mux.MiddlewareFunc {
 	return func(next http.Handler) http.Handler {
 		return l.LoggingMiddleware()(next)
 	}
 }
+
+// requestLogInfo collects details learned while serving a request,
+// such as the authenticated user, for its access log record.
+type requestLogInfo struct {
+	user string
+	err  error
+}
+
+type requestLogInfoKey struct{}
+
+// setLogIdentity records the identity of the caller in the access log record of the request.
+func setLogIdentity(ctx context.Context, id auth.Identity) {
+	if info, ok := ctx.Value(requestLogInfoKey{}).(*requestLogInfo); ok {
+		info.user = id.Subject
+	}
+}
+
+// LogError records err in the access log record of the request, so that the
+// cause of a server error is kept server-side while its client gets a generic detail.
+func LogError(ctx context.Context, err error) {
+	if info, ok := ctx.Value(requestLogInfoKey{}).(*requestLogInfo); ok {
+		info.err = err
+	}
+}
+
+// statusRecorder wraps a ResponseWriter to record the status code and response size.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// WriteHeader records the status code and forwards it.
+func (rec *statusRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the size of the body and forwards it.
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	n, err := rec.ResponseWriter.Write(b)
+	rec.bytes += int64(n)
+	return n, err
+}
+
+// Flush forwards flushes to streaming-capable writers.
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// Status returns the recorded status code; 200 when the handler wrote nothing.
+func (rec *statusRecorder) Status() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
@@ -0,0 +1,32 @@
+// middleware/metrics.go
+package middleware
+
+import (
+	"estiam/metrics"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// NewMetricsMiddleware returns a middleware recording the count, latency and
+// status of requests by route template, and the requests in flight.
+func NewMetricsMiddleware(m *metrics.Metrics) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			m.InFlight.Inc()
+			defer m.InFlight.Dec()
+
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			// Label by route template rather than path, so that words do not create series.
+			route := "unmatched"
+			if current := mux.CurrentRoute(r); current != nil {
+				route, _ = current.GetPathTemplate()
+			}
+			m.ObserveRequest(r.Method, route, rec.Status(), time.Since(start))
+		})
+	}
+}
@@ -0,0 +1,91 @@
+// middleware/problem.go
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ProblemContentType is the media type of RFC 7807 problem details.
+const ProblemContentType = "application/problem+json"
+
+// Problem type URIs. Problems without a more specific type use "about:blank".
+const (
+	ProblemTypeBlank      = "about:blank"
+	ProblemTypeValidation = "/problems/validation-error"
+)
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type      string           `json:"type"`
+	Title     string           `json:"title"`
+	Status    int              `json:"status"`
+	Detail    string           `json:"detail,omitempty"`
+	Instance  string           `json:"instance,omitempty"`
+	RequestID string           `json:"request_id,omitempty"`
+	Errors    ValidationErrors `json:"errors,omitempty"`
+}
+
+// NewProblem creates a Problem of type about:blank for the given status code.
+func NewProblem(statusCode int, detail string) Problem {
+	return Problem{
+		Type:   ProblemTypeBlank,
+		Title:  http.StatusText(statusCode),
+		Status: statusCode,
+		Detail: detail,
+	}
+}
+
+// WriteProblem sends p as application/problem+json. The request ID is taken from
+// the response headers set by RequestIDMiddleware when p does not carry one.
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	if p.RequestID == "" {
+		p.RequestID = w.Header().Get(RequestIDHeader)
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// ProblemHandler returns a handler answering every request with the given status,
+// for use as the router's NotFoundHandler or MethodNotAllowedHandler.
+func ProblemHandler(statusCode int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = withRequestID(w, r)
+
+		p := NewProblem(statusCode, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+		p.Instance = r.URL.Path
+		WriteProblem(w, p)
+	})
+}
+
+// allowCandidates are the methods tried when listing those allowed for a path.
+var allowCandidates = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+
+// MethodNotAllowedHandler returns a handler answering 405 Method Not Allowed with
+// an Allow header listing the methods router serves for the request path, for
+// use as its MethodNotAllowedHandler.
+func MethodNotAllowedHandler(router *mux.Router) http.Handler {
+	problem := ProblemHandler(http.StatusMethodNotAllowed)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, method := range allowCandidates {
+			candidate := r.Clone(r.Context())
+			candidate.Method = method
+			var match mux.RouteMatch
+			if router.Match(candidate, &match) && match.MatchErr == nil {
+				allowed = append(allowed, method)
+			}
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+
+		problem.ServeHTTP(w, r)
+	})
+}
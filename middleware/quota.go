@@ -0,0 +1,150 @@
+// middleware/quota.go
+package middleware
+
+import (
+	"context"
+	"estiam/auth"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// QuotaStore counts requests per client and UTC day.
+type QuotaStore interface {
+	// Increment adds one request for client on day and returns the new count.
+	Increment(ctx context.Context, client string, day string) (int64, error)
+}
+
+// QuotaPolicy configures the daily quota of authenticated clients.
+type QuotaPolicy struct {
+	// Limit is the number of requests allowed per client and UTC day; 0 disables the quota.
+	Limit int64
+	// FailOpen lets requests through when the store cannot count them, instead
+	// of answering 503 Service Unavailable.
+	FailOpen bool
+	// Logger records store errors; slog.Default() when nil.
+	Logger *slog.Logger
+}
+
+// NewQuotaMiddleware returns a middleware allowing each authenticated client at most
+// policy.Limit requests per UTC day, counted in store so that quotas survive restarts
+// and are shared between instances. Anonymous callers are only rate limited. It must
+// run after the auth middleware.
+func NewQuotaMiddleware(store QuotaStore, policy QuotaPolicy) mux.MiddlewareFunc {
+	logger := policy.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	limit := policy.Limit
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := auth.IdentityFromContext(r.Context())
+			if limit <= 0 || !ok || id.Anonymous {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			now := time.Now().UTC()
+			count, err := store.Increment(r.Context(), clientKey(r), now.Format("2006-01-02"))
+			if err != nil {
+				logger.LogAttrs(r.Context(), slog.LevelError, "counting quota",
+					slog.String("error", err.Error()),
+					slog.String("client", clientKey(r)),
+					slog.Bool("fail_open", policy.FailOpen),
+					slog.String("request_id", RequestIDFromContext(r.Context())),
+				)
+				if policy.FailOpen {
+					next.ServeHTTP(w, r)
+					return
+				}
+				HandleError(w, "quota service unavailable", http.StatusServiceUnavailable)
+				return
+			}
+
+			remaining := limit - count
+			if remaining < 0 {
+				remaining = 0
+			}
+			w.Header().Set("X-Quota-Limit", strconv.FormatInt(limit, 10))
+			w.Header().Set("X-Quota-Remaining", strconv.FormatInt(remaining, 10))
+
+			if count > limit {
+				midnight := now.Truncate(24 * time.Hour).Add(24 * time.Hour)
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(midnight.Sub(now))))
+				HandleError(w, fmt.Sprintf("daily quota of %d requests exceeded", limit), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// quotaRetention is how long daily counters are kept in MongoDB.
+const quotaRetention = 7 * 24 * time.Hour
+
+// MongoQuotaStore is a QuotaStore backed by a MongoDB collection.
+type MongoQuotaStore struct {
+	collection *mongo.Collection
+}
+
+// NewMongoQuotaStore creates a QuotaStore using the given collection. Counters
+// expire after a week through a TTL index.
+func NewMongoQuotaStore(ctx context.Context, collection *mongo.Collection) (*MongoQuotaStore, error) {
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "updated_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(int32(quotaRetention.Seconds())),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MongoQuotaStore{collection: collection}, nil
+}
+
+// Increment adds one request for client on day and returns the new count.
+func (s *MongoQuotaStore) Increment(ctx context.Context, client string, day string) (int64, error) {
+	var counter struct {
+		Count int64 `bson:"count"`
+	}
+	err := s.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": client + "|" + day},
+		bson.M{
+			"$inc": bson.M{"count": 1},
+			"$set": bson.M{"updated_at": time.Now().UTC()},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Count, nil
+}
+
+// MemoryQuotaStore is an in-process QuotaStore, for tests and development.
+type MemoryQuotaStore struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+// NewMemoryQuotaStore creates an empty MemoryQuotaStore.
+func NewMemoryQuotaStore() *MemoryQuotaStore {
+	return &MemoryQuotaStore{counts: map[string]int64{}}
+}
+
+// Increment adds one request for client on day and returns the new count.
+func (s *MemoryQuotaStore) Increment(ctx context.Context, client string, day string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counts[client+"|"+day]++
+	return s.counts[client+"|"+day], nil
+}
@@ -0,0 +1,220 @@
+// middleware/ratelimit.go
+package middleware
+
+import (
+	"context"
+	"estiam/auth"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// RateLimit is a token bucket: PerMinute requests per minute on average, with
+// bursts of up to Burst requests. A zero PerMinute disables the limit.
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+// RateLimitPolicy configures the limits of a route class, such as reads or writes.
+type RateLimitPolicy struct {
+	// Class names the route class in responses, e.g. "read".
+	Class string
+	// Limit applies per API key or authenticated subject.
+	Limit RateLimit
+	// AnonymousLimit applies per client IP to anonymous callers.
+	AnonymousLimit RateLimit
+}
+
+// bucketIdleTimeout is how long an unused bucket is kept before it is swept.
+const bucketIdleTimeout = 10 * time.Minute
+
+// bucket is the token bucket of one client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter holds the token buckets of one rate limit, keyed by client.
+type RateLimiter struct {
+	limit RateLimit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewRateLimiter creates a RateLimiter enforcing limit.
+func NewRateLimiter(limit RateLimit) *RateLimiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+	return &RateLimiter{limit: limit, now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// Allow takes a token from the bucket of key. It returns whether the request is
+// allowed, the tokens left, and how long until the next token is available.
+func (l *RateLimiter) Allow(key string) (bool, int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	rate := float64(l.limit.PerMinute) / 60 // tokens per second
+	burst := float64(l.limit.Burst)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	wait := time.Duration(0)
+	if b.tokens < 1 {
+		wait = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	return allowed, int(b.tokens), wait
+}
+
+// Peek reports whether the bucket of key holds a token, without taking it, and
+// how long until it does.
+func (l *RateLimiter) Peek(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		return true, 0
+	}
+	rate := float64(l.limit.PerMinute) / 60
+	tokens := math.Min(float64(l.limit.Burst), b.tokens+l.now().Sub(b.last).Seconds()*rate)
+	if tokens >= 1 {
+		return true, 0
+	}
+	return false, time.Duration((1 - tokens) / rate * float64(time.Second))
+}
+
+// sweep drops buckets that have been idle long enough to be full again.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < bucketIdleTimeout {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > bucketIdleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// NewRateLimitMiddleware returns a middleware enforcing policy per client. It must
+// run after the auth middleware: callers are keyed by API key or subject, and
+// anonymous callers by IP. Responses carry RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers; rejected requests get 429 with a Retry-After header.
+func NewRateLimitMiddleware(policy RateLimitPolicy) mux.MiddlewareFunc {
+	limiter := NewRateLimiter(policy.Limit)
+	anonymous := NewRateLimiter(policy.AnonymousLimit)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key, limit, l := clientKey(r), policy.Limit, limiter
+			if id, ok := auth.IdentityFromContext(r.Context()); !ok || id.Anonymous {
+				limit, l = policy.AnonymousLimit, anonymous
+			}
+			if limit.PerMinute <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, remaining, wait := l.Allow(policy.Class + "|" + key)
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(limit.PerMinute))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(wait)))
+
+			if !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(wait)))
+				HandleError(w, fmt.Sprintf("rate limit of %d %s requests per minute exceeded", limit.PerMinute, policy.Class), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// NewAuthFailureLimitMiddleware returns a middleware limiting failed
+// authentications per client IP, so that credentials cannot be guessed at will.
+// It must run before the auth middleware, and after the client IP middleware
+// when the server is behind proxies: each set of credentials rejected by the
+// auth middleware takes a token from the bucket of the IP, and once it is
+// empty, requests from the IP are answered 429 without reaching authentication
+// until a token is refilled. Requests without credentials, and credentials that
+// could not be checked, are not counted.
+func NewAuthFailureLimitMiddleware(limit RateLimit) mux.MiddlewareFunc {
+	limiter := NewRateLimiter(limit)
+
+	return func(next http.Handler) http.Handler {
+		if limit.PerMinute <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r)
+			if ok, wait := limiter.Peek(ip); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(wait)))
+				HandleError(w, "too many failed authentications", http.StatusTooManyRequests)
+				return
+			}
+
+			attempt := &authAttempt{}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), authAttemptKey{}, attempt)))
+			if attempt.rejected {
+				limiter.Allow(ip)
+			}
+		})
+	}
+}
+
+// authAttempt records whether the auth middleware rejected the credentials of a request.
+type authAttempt struct {
+	rejected bool
+}
+
+type authAttemptKey struct{}
+
+// recordAuthRejection tells the auth failure limiter that the credentials of the request were rejected.
+func recordAuthRejection(ctx context.Context) {
+	if attempt, ok := ctx.Value(authAttemptKey{}).(*authAttempt); ok {
+		attempt.rejected = true
+	}
+}
+
+// clientKey identifies the caller of r for rate limiting and quotas.
+func clientKey(r *http.Request) string {
+	if id, ok := auth.IdentityFromContext(r.Context()); ok && !id.Anonymous {
+		if id.KeyID != "" {
+			return "key:" + id.KeyID
+		}
+		return "sub:" + id.Subject
+	}
+	return "ip:" + clientIP(r)
+}
+
+// ceilSeconds rounds d up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
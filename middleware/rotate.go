@@ -0,0 +1,322 @@
+// middleware/rotate.go
+package middleware
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotationOptions configures when a RotatingFile is rotated and how many old
+// segments are kept. Zero values disable the corresponding behaviour.
+type RotationOptions struct {
+	// MaxSize rotates the file before it grows beyond this many bytes.
+	MaxSize int64
+	// Interval rotates the file once it has been written to for this long.
+	Interval time.Duration
+	// MaxAge removes rotated segments older than this.
+	MaxAge time.Duration
+	// MaxBackups keeps at most this many rotated segments.
+	MaxBackups int
+	// Compress gzips rotated segments.
+	Compress bool
+}
+
+// backupTimeFormat stamps rotated segments, e.g. jornale-2024-05-01T10-00-00.000.txt.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile is an io.Writer appending to a file that is rotated by size and
+// age. Rotated segments are renamed with a timestamp, optionally compressed, and
+// pruned in the background.
+type RotatingFile struct {
+	path    string
+	options RotationOptions
+	now     func() time.Time
+	rename  func(oldpath, newpath string) error
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+	closed bool
+	// retryAt postpones rotations after one failed, so that each write does not retry it.
+	retryAt time.Time
+
+	// maintenance tracks the background compression and pruning of segments,
+	// which maintaining serializes so that pruning never sees a segment being compressed.
+	maintenance sync.WaitGroup
+	maintaining sync.Mutex
+}
+
+// OpenRotatingFile opens or creates path for appending.
+func OpenRotatingFile(path string, opts RotationOptions) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, options: opts, now: time.Now, rename: os.Rename}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open opens the file at f.path. The caller holds f.mu or owns f exclusively.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	// An existing file was started before this process; count its age from its
+	// last write, so that restarts do not postpone its rotation indefinitely.
+	opened := f.now()
+	if info.Size() > 0 && info.ModTime().Before(opened) {
+		opened = info.ModTime()
+	}
+
+	f.file, f.size, f.opened = file, info.Size(), opened
+	return nil
+}
+
+// rotationRetry is how long writes go to the current file after a failed rotation.
+const rotationRetry = time.Minute
+
+// Write appends p to the file, rotating it first when p would exceed MaxSize or
+// the file is older than Interval. A failed rotation is reported on stderr and
+// p is appended to the current file, so that logging goes on.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return 0, os.ErrClosed
+	}
+
+	now := f.now()
+	tooBig := f.options.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.options.MaxSize
+	tooOld := f.options.Interval > 0 && now.Sub(f.opened) >= f.options.Interval
+	if (tooBig || tooOld) && !now.Before(f.retryAt) {
+		if err := f.rotate(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error rotating log file:", err)
+			f.retryAt = now.Add(rotationRetry)
+		}
+	}
+
+	// Reopen a file that could not be reopened after a rotation.
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Rotate closes the current file, renames it with a timestamp and starts a new one.
+func (f *RotatingFile) Rotate() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	return f.rotate()
+}
+
+// rotate renames the current file and opens a new one. When the file cannot
+// be renamed, it is reopened in place and the error is returned. The caller holds f.mu.
+func (f *RotatingFile) rotate() error {
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+
+	// Never overwrite a segment rotated within the same millisecond.
+	rotatedAt := f.now()
+	backup := f.backupName(rotatedAt)
+	for exists(backup) || exists(backup+".gz") {
+		rotatedAt = rotatedAt.Add(time.Millisecond)
+		backup = f.backupName(rotatedAt)
+	}
+	if err := f.rename(f.path, backup); err != nil && !os.IsNotExist(err) {
+		if openErr := f.open(); openErr != nil {
+			return errors.Join(fmt.Errorf("rotating %s: %w", f.path, err), openErr)
+		}
+		return fmt.Errorf("rotating %s: %w", f.path, err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.maintenance.Add(1)
+	go func() {
+		defer f.maintenance.Done()
+		if err := f.maintain(backup); err != nil {
+			fmt.Fprintln(os.Stderr, "Error maintaining log segments:", err)
+		}
+	}()
+	return nil
+}
+
+// Reopen closes and reopens the file at the same path, so that an external tool
+// such as logrotate can move it away and have writes go to a fresh file.
+func (f *RotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.closed {
+		return os.ErrClosed
+	}
+	if f.file != nil {
+		if err := f.file.Close(); err != nil {
+			return err
+		}
+		f.file = nil
+	}
+	return f.open()
+}
+
+// Close closes the file and waits for background compression and pruning.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	f.closed = true
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.maintenance.Wait()
+	return err
+}
+
+// backupName returns the name of a segment rotated at t.
+func (f *RotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.path)
+	return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(f.path, ext), t.Format(backupTimeFormat), ext)
+}
+
+// maintain compresses the segment just rotated and prunes old segments. Runs
+// are serialized; a segment pruned by an earlier run is not compressed.
+func (f *RotatingFile) maintain(backup string) error {
+	f.maintaining.Lock()
+	defer f.maintaining.Unlock()
+
+	if f.options.Compress {
+		if err := compressFile(backup); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return f.prune()
+}
+
+// prune removes the segments beyond MaxBackups or older than MaxAge.
+func (f *RotatingFile) prune() error {
+	if f.options.MaxBackups <= 0 && f.options.MaxAge <= 0 {
+		return nil
+	}
+
+	backups, err := f.Backups()
+	if err != nil {
+		return err
+	}
+
+	// Backups are sorted newest first.
+	cutoff := f.now().Add(-f.options.MaxAge)
+	for i, b := range backups {
+		expired := f.options.MaxAge > 0 && b.RotatedAt.Before(cutoff)
+		extra := f.options.MaxBackups > 0 && i >= f.options.MaxBackups
+		if expired || extra {
+			if err := os.Remove(b.Path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LogSegment is a rotated segment of a RotatingFile.
+type LogSegment struct {
+	Path      string
+	RotatedAt time.Time
+}
+
+// Backups lists the rotated segments of the file, newest first.
+func (f *RotatingFile) Backups() ([]LogSegment, error) {
+	ext := filepath.Ext(f.path)
+	prefix := filepath.Base(strings.TrimSuffix(f.path, ext)) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []LogSegment
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".gz")
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+		t, err := time.ParseInLocation(backupTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, LogSegment{Path: filepath.Join(filepath.Dir(f.path), e.Name()), RotatedAt: t})
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].RotatedAt.After(segments[j].RotatedAt) })
+	return segments, nil
+}
+
+// exists reports whether a file exists at path.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// compressFile gzips path to path.gz and removes path.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	in.Close()
+	return os.Remove(path)
+}
@@ -2,38 +2,235 @@
 package middleware
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Validation rule names reported in ValidationError.Rule.
+const (
+	RuleEncoding          = "encoding"
+	RuleMinLength         = "min_length"
+	RuleMaxLength         = "max_length"
+	RuleAllowedCharacters = "allowed_characters"
 )
 
+// Language describes the character classes accepted in words for a language.
+type Language struct {
+	// Code is the language code, e.g. "en" or "fr".
+	Code string
+	// Scripts lists the Unicode scripts whose letters may appear in a word.
+	// An empty list accepts letters from any script.
+	Scripts []*unicode.RangeTable
+}
+
+// Languages holds the languages known to the validator, keyed by code.
+// The empty code accepts letters from every script.
+var Languages = map[string]Language{
+	"":   {Code: ""},
+	"en": {Code: "en", Scripts: []*unicode.RangeTable{unicode.Latin}},
+	"fr": {Code: "fr", Scripts: []*unicode.RangeTable{unicode.Latin}},
+	"de": {Code: "de", Scripts: []*unicode.RangeTable{unicode.Latin}},
+	"es": {Code: "es", Scripts: []*unicode.RangeTable{unicode.Latin}},
+	"it": {Code: "it", Scripts: []*unicode.RangeTable{unicode.Latin}},
+	"pt": {Code: "pt", Scripts: []*unicode.RangeTable{unicode.Latin}},
+	"ru": {Code: "ru", Scripts: []*unicode.RangeTable{unicode.Cyrillic}},
+	"uk": {Code: "uk", Scripts: []*unicode.RangeTable{unicode.Cyrillic}},
+	"el": {Code: "el", Scripts: []*unicode.RangeTable{unicode.Greek}},
+	"ar": {Code: "ar", Scripts: []*unicode.RangeTable{unicode.Arabic}},
+	"he": {Code: "he", Scripts: []*unicode.RangeTable{unicode.Hebrew}},
+}
+
+// wordPunctuation lists the punctuation allowed inside a word,
+// e.g. "self-esteem", "don't", "e.g." or "snake_case".
+const wordPunctuation = "-'’‐._ "
+
+// ValidationError describes a single failed validation rule.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors is the list of rules an entry failed.
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "invalid data: " + strings.Join(messages, "; ")
+}
+
+// Validator checks words and definitions against configurable rules.
+// Lengths are counted in runes after NFC normalization; a zero maximum means no limit.
+type Validator struct {
+	Language            string
+	MinWordLength       int
+	MaxWordLength       int
+	MinDefinitionLength int
+	MaxDefinitionLength int
+}
+
+// DefaultValidator is the validator used by ValidateData.
+var DefaultValidator = &Validator{
+	MinWordLength:       3,
+	MaxWordLength:       64,
+	MinDefinitionLength: 5,
+	MaxDefinitionLength: 2000,
+}
+
+// NewValidator creates a Validator for the given language with the default lengths.
+func NewValidator(language string) (*Validator, error) {
+	if _, ok := Languages[language]; !ok {
+		return nil, fmt.Errorf("unsupported language: %q", language)
+	}
+
+	v := *DefaultValidator
+	v.Language = language
+	return &v, nil
+}
+
+// ValidateData validates a word and its definition with the DefaultValidator.
 func ValidateData(word, definition string) error {
-	// Validate data length
-	if len(word) < 3 || len(definition) < 5 {
-		return fmt.Errorf("invalid data: Word and definition must be at least 3 and 5 characters long, respectively")
+	_, _, err := DefaultValidator.Validate(word, definition)
+	return err
+}
+
+// Validate trims and NFC-normalizes the word and definition, then checks them.
+// It returns the normalized values, or ValidationErrors naming every failed rule.
+func (v *Validator) Validate(word, definition string) (string, string, error) {
+	var errs ValidationErrors
+
+	word, err := normalize("word", word)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	definition, err = normalize("definition", definition)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return "", "", errs
+	}
+
+	language, ok := Languages[v.Language]
+	if !ok {
+		language = Languages[""]
+	}
+
+	errs = append(errs, checkLength("word", word, v.MinWordLength, v.MaxWordLength)...)
+	if r, ok := firstRejected(word, language.allowsInWord); ok {
+		errs = append(errs, &ValidationError{
+			Field:   "word",
+			Rule:    RuleAllowedCharacters,
+			Message: fmt.Sprintf("character %q is not allowed", r),
+		})
 	}
 
-	// Validate no special characters in word and definition
-	if containsSpecialCharacters(word) || containsSpecialCharacters(definition) {
-		return fmt.Errorf("invalid data: Word and definition must not contain special characters")
+	errs = append(errs, checkLength("definition", definition, v.MinDefinitionLength, v.MaxDefinitionLength)...)
+	if r, ok := firstRejected(definition, allowsInDefinition); ok {
+		errs = append(errs, &ValidationError{
+			Field:   "definition",
+			Rule:    RuleAllowedCharacters,
+			Message: fmt.Sprintf("character %q is not allowed", r),
+		})
 	}
 
+	if len(errs) > 0 {
+		return "", "", errs
+	}
+	return word, definition, nil
+}
+
+// normalize rejects invalid UTF-8 and returns the trimmed NFC form of s.
+func normalize(field, s string) (string, *ValidationError) {
+	if !utf8.ValidString(s) {
+		return "", &ValidationError{Field: field, Rule: RuleEncoding, Message: "must be valid UTF-8"}
+	}
+	return norm.NFC.String(strings.TrimSpace(s)), nil
+}
+
+// checkLength checks the rune count of s against min and max.
+func checkLength(field, s string, min, max int) []*ValidationError {
+	n := utf8.RuneCountInString(s)
+	if n < min {
+		return []*ValidationError{{
+			Field:   field,
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("must be at least %d characters long", min),
+		}}
+	}
+	if max > 0 && n > max {
+		return []*ValidationError{{
+			Field:   field,
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("must be at most %d characters long", max),
+		}}
+	}
 	return nil
 }
 
-// checks if a string contains special characters
-func containsSpecialCharacters(s string) bool {
-	specialCharacters := "~!@#$%^&*()-+={}[]|;:'\",.<>?/"
-	for _, char := range s {
-		if strings.ContainsRune(specialCharacters, char) {
-			return true
+// firstRejected returns the first rune of s that allowed rejects.
+func firstRejected(s string, allowed func(rune) bool) (rune, bool) {
+	for _, r := range s {
+		if !allowed(r) {
+			return r, true
 		}
 	}
+	return 0, false
+}
+
+// allowsInWord reports whether r may appear in a word of the language.
+func (l Language) allowsInWord(r rune) bool {
+	switch {
+	case strings.ContainsRune(wordPunctuation, r):
+		return true
+	case unicode.IsDigit(r), unicode.IsMark(r):
+		return true
+	case unicode.IsLetter(r):
+		return len(l.Scripts) == 0 || unicode.IsOneOf(l.Scripts, r)
+	}
 	return false
 }
 
-// handles errors by logging and sending an appropriate HTTP response
+// allowsInDefinition reports whether r may appear in a definition.
+// Letters, marks, numbers, punctuation, spaces and mathematical, currency and
+// modifier symbols are accepted; control, format, private-use and other
+// symbols (which include emoji) are not.
+func allowsInDefinition(r rune) bool {
+	if r == ' ' {
+		return true
+	}
+	return unicode.In(r, unicode.L, unicode.M, unicode.N, unicode.P, unicode.Zs, unicode.Sm, unicode.Sc, unicode.Sk)
+}
+
+// handles errors by logging and sending an appropriate problem+json response
 func HandleError(w http.ResponseWriter, message string, statusCode int) {
 	fmt.Println("Error:", message)
-	http.Error(w, message, statusCode)
+	WriteProblem(w, NewProblem(statusCode, message))
+}
+
+// HandleValidationError sends a 400 problem listing every failed validation rule.
+func HandleValidationError(w http.ResponseWriter, err error) {
+	fmt.Println("Error:", err)
+
+	p := NewProblem(http.StatusBadRequest, err.Error())
+	var errs ValidationErrors
+	if errors.As(err, &errs) {
+		p.Type = ProblemTypeValidation
+		p.Title = "Invalid entry"
+		p.Errors = errs
+	}
+	WriteProblem(w, p)
 }
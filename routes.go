@@ -0,0 +1,164 @@
+// routes.go
+package main
+
+import (
+	"estiam/auth"
+	"estiam/config"
+	"estiam/dictionary"
+	"estiam/docs"
+	"estiam/handlers"
+	"estiam/metrics"
+	"estiam/middleware"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// server holds the dependencies shared by the routes.
+type server struct {
+	config        config.Config
+	dictionary    *dictionary.Dictionary
+	keys          *auth.KeyManager
+	authenticator auth.Authenticator
+	logger        *middleware.Logger
+	quotas        middleware.QuotaStore
+	metrics       *metrics.Metrics
+}
+
+// The routes that put verbs in their path are deprecated aliases of /v1/words,
+// removed after the sunset date.
+var (
+	legacyDeprecatedAt = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	legacySunset       = time.Date(2027, 4, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// newRouter creates the router serving the probes, the metrics, and the read,
+// write and admin route groups. Read routes are served anonymously when
+// auth.public_read is set; write and admin routes always require credentials.
+func newRouter(s server) *mux.Router {
+	// Create a new Gorilla Mux router that answers unknown routes and methods with problem+json.
+	r := mux.NewRouter()
+	r.NotFoundHandler = middleware.ProblemHandler(http.StatusNotFound)
+	r.MethodNotAllowedHandler = middleware.MethodNotAllowedHandler(r)
+
+	// Compress large responses, such as listings, for clients that accept it.
+	if compression := s.config.Server.Compression; compression.Enabled {
+		r.Use(middleware.NewCompressionMiddleware(compression.MinSize))
+	}
+
+	// Serve probes and docs ahead of the API middlewares, so that they bypass
+	// authentication, rate limits and the access log.
+	r.Handle("/healthz", handlers.HealthHandler()).Methods("GET")
+	r.Handle("/readyz", handlers.ReadinessHandler(map[string]handlers.Check{
+		"mongodb": s.dictionary.Ping,
+	}, time.Duration(s.config.Server.ReadinessTimeout), s.logger.Structured())).Methods("GET")
+	// Describe the API with OpenAPI, and render it for people.
+	r.Handle("/openapi.json", docs.SpecHandler()).Methods("GET")
+	r.Handle("/docs", docs.PageHandler()).Methods("GET")
+
+	// Every other route belongs to the API.
+	api := r.NewRoute().Subrouter()
+
+	// Resolve the client IP of requests forwarded by trusted proxies, for rate limits and logs.
+	api.Use(middleware.NewClientIPMiddleware(s.config.Server.TrustedProxies))
+
+	// Assign every request an ID used in logs and error responses.
+	api.Use(middleware.RequestIDMiddleware)
+
+	// Bound the size of request bodies.
+	api.Use(middleware.MaxBodySize(s.config.Server.MaxBodyBytes))
+
+	// Use the logger middleware for logging requests.
+	api.Use(s.logger.MiddlewareFunc())
+
+	// Count requests and their latencies by route.
+	api.Use(middleware.NewMetricsMiddleware(s.metrics))
+
+	// Limit failed authentications per IP, ahead of authentication.
+	api.Use(middleware.NewAuthFailureLimitMiddleware(rateLimit(s.config.RateLimit.AuthFailures)))
+
+	// Authenticate API keys or JWTs; read routes may also be served anonymously.
+	authenticate := middleware.NewAuthMiddleware(s.authenticator)
+	readAuthenticate := authenticate
+	if s.config.Auth.PublicRead {
+		readAuthenticate = middleware.NewOptionalAuthMiddleware(s.authenticator)
+	}
+
+	// Limit request rates per route class, and daily requests per client.
+	limits := s.config.RateLimit
+	readLimit := middleware.NewRateLimitMiddleware(middleware.RateLimitPolicy{
+		Class:          "read",
+		Limit:          rateLimit(limits.Read),
+		AnonymousLimit: rateLimit(limits.AnonymousRead),
+	})
+	writeLimit := middleware.NewRateLimitMiddleware(middleware.RateLimitPolicy{Class: "write", Limit: rateLimit(limits.Write)})
+	adminLimit := middleware.NewRateLimitMiddleware(middleware.RateLimitPolicy{Class: "admin", Limit: rateLimit(limits.Admin)})
+	quota := middleware.NewQuotaMiddleware(s.quotas, middleware.QuotaPolicy{
+		Limit:    limits.DailyQuota,
+		FailOpen: limits.QuotaFailOpen,
+		Logger:   s.logger.Structured(),
+	})
+
+	// Require a scope per route; denials are recorded in the access log.
+	read := middleware.RequireScope(auth.ScopeRead, s.logger.Logger)
+	write := middleware.RequireScope(auth.ScopeWrite, s.logger.Logger)
+	remove := middleware.RequireScope(auth.ScopeDelete, s.logger.Logger)
+	admin := middleware.RequireScope(auth.ScopeAdmin, s.logger.Logger)
+
+	// Point clients of the legacy routes to their successor.
+	deprecated := func(successor string) mux.MiddlewareFunc {
+		return middleware.Deprecated(legacyDeprecatedAt, legacySunset, successor)
+	}
+
+	// Define read routes: lookups and listings.
+	reads := api.NewRoute().Subrouter()
+	reads.Use(readAuthenticate, readLimit, quota)
+	reads.Use(middleware.CacheControl(time.Duration(s.config.Server.CacheMaxAge), s.config.Auth.PublicRead))
+	reads.Handle("/v1/words", read(handlers.ListWordsHandler(s.dictionary))).Methods("GET")
+	reads.Handle("/v1/words/{word}", read(handlers.GetDefinitionHandler(s.dictionary))).Methods("GET")
+	reads.Handle("/get/{word}", deprecated("/v1/words/{word}")(read(handlers.GetDefinitionHandler(s.dictionary)))).Methods("GET")
+	reads.Handle("/list", deprecated("/v1/words")(read(handlers.ListWordsHandler(s.dictionary)))).Methods("GET")
+
+	// Define write routes: changes to the dictionary.
+	writes := api.NewRoute().Subrouter()
+	writes.Use(authenticate, writeLimit, quota)
+	writes.Handle("/v1/words", write(handlers.CreateWordHandler(s.dictionary))).Methods("POST")
+	writes.Handle("/v1/words/{word}", write(handlers.PutWordHandler(s.dictionary))).Methods("PUT")
+	writes.Handle("/v1/words/{word}", write(handlers.PatchWordHandler(s.dictionary))).Methods("PATCH")
+	writes.Handle("/v1/words/{word}", remove(handlers.RemoveEntryHandler(s.dictionary))).Methods("DELETE")
+	writes.Handle("/add", deprecated("/v1/words")(write(handlers.AddEntryHandler(s.dictionary)))).Methods("POST")
+	writes.Handle("/remove/{word}", deprecated("/v1/words/{word}")(remove(handlers.RemoveEntryHandler(s.dictionary)))).Methods("DELETE")
+
+	// Define admin routes.
+	admins := api.PathPrefix("/admin").Subrouter()
+	admins.Use(authenticate, adminLimit, quota)
+	admins.Handle("/config", admin(handlers.ConfigHandler(s.config))).Methods("GET")
+	admins.Handle("/keys", admin(handlers.CreateKeyHandler(s.keys))).Methods("POST")
+	admins.Handle("/keys", admin(handlers.ListKeysHandler(s.keys))).Methods("GET")
+	admins.Handle("/keys/{id}", admin(handlers.RevokeKeyHandler(s.keys))).Methods("DELETE")
+
+	// Serve metrics to admins, unless they have a listener of their own.
+	if path := s.config.Server.MetricsPath; path != "" && s.config.Server.MetricsAddr == "" {
+		scrapes := api.NewRoute().Subrouter()
+		scrapes.Use(authenticate, adminLimit)
+		scrapes.Handle(path, admin(s.metrics.Handler())).Methods("GET")
+	}
+
+	return r
+}
+
+// newMetricsRouter creates the router of the separate metrics listener, serving
+// the metrics without authentication.
+func newMetricsRouter(s server) *mux.Router {
+	r := mux.NewRouter()
+	r.NotFoundHandler = middleware.ProblemHandler(http.StatusNotFound)
+	r.MethodNotAllowedHandler = middleware.MethodNotAllowedHandler(r)
+	r.Handle(s.config.Server.MetricsPath, s.metrics.Handler()).Methods("GET")
+	return r
+}
+
+// rateLimit converts a configured rate limit rule to a middleware.RateLimit.
+func rateLimit(rule config.RateLimitRule) middleware.RateLimit {
+	return middleware.RateLimit{PerMinute: rule.PerMinute, Burst: rule.Burst}
+}
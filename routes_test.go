@@ -0,0 +1,118 @@
+// routes_test.go
+package main
+
+import (
+	"context"
+	"estiam/auth"
+	"estiam/config"
+	"estiam/docs"
+	"estiam/metrics"
+	"estiam/middleware"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRouter creates the router of the server over in-memory stores. The
+// dictionary is left nil, so handlers using it must not be called.
+func newTestRouter(t *testing.T) *mux.Router {
+	return newRouter(newTestServer(t, config.Default()))
+}
+
+// newTestServer creates a server with cfg over in-memory stores and no dictionary.
+func newTestServer(t *testing.T, cfg config.Config) server {
+	logger, err := middleware.NewLogger(filepath.Join(t.TempDir(), "access.log"))
+	assert.NoError(t, err)
+	t.Cleanup(func() { logger.Close() })
+
+	keys := auth.NewKeyManager(auth.NewMemoryKeyStore())
+	return server{
+		config:        cfg,
+		keys:          keys,
+		authenticator: keys,
+		logger:        logger,
+		quotas:        middleware.NewMemoryQuotaStore(),
+		metrics:       metrics.New(),
+	}
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	// 1. Parse the served OpenAPI document.
+	spec, err := docs.ParseSpec()
+	assert.NoError(t, err)
+
+	// 2. Every method of every registered route must be documented.
+	registered := map[string]bool{}
+	err = newTestRouter(t).Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return nil // subrouters without a path of their own
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil // path prefixes of subrouters
+		}
+		for _, method := range methods {
+			method = strings.ToLower(method)
+			registered[method+" "+path] = true
+			_, ok := spec.Paths[path][method]
+			assert.True(t, ok, "%s %s is not in docs/openapi.json", strings.ToUpper(method), path)
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+
+	// 3. The document does not describe routes that do not exist.
+	for path, item := range spec.Paths {
+		for method := range item {
+			if method != "parameters" {
+				assert.True(t, registered[method+" "+path], "%s %s is documented but not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestMethodNotAllowedListsRouteMethods(t *testing.T) {
+	// 1. Send a method no route serves for an existing path.
+	w := httptest.NewRecorder()
+	newTestRouter(t).ServeHTTP(w, httptest.NewRequest("POST", "/v1/words/chat", nil))
+
+	// 2. Verify that every method of the path, from every route group, is allowed.
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, PUT, PATCH, DELETE", w.Header().Get("Allow"))
+}
+
+func TestMetricsRequireAdmin(t *testing.T) {
+	s := newTestServer(t, config.Default())
+	r := newRouter(s)
+	scrape := func(handler http.Handler, token string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		handler.ServeHTTP(w, req)
+		return w.Code
+	}
+	_, reader, err := s.keys.Create(context.Background(), "reader", []string{"reader"})
+	assert.NoError(t, err)
+	_, admin, err := s.keys.Create(context.Background(), "ops", []string{"admin"})
+	assert.NoError(t, err)
+
+	// 1. On the API listener, metrics are only served to admins.
+	assert.Equal(t, http.StatusUnauthorized, scrape(r, ""))
+	assert.Equal(t, http.StatusForbidden, scrape(r, reader))
+	assert.Equal(t, http.StatusOK, scrape(r, admin))
+
+	// 2. With a listener of their own, they leave the API and need no credentials there.
+	cfg := config.Default()
+	cfg.Server.MetricsAddr = "127.0.0.1:9090"
+	s = newTestServer(t, cfg)
+	assert.Equal(t, http.StatusNotFound, scrape(newRouter(s), ""))
+	assert.Equal(t, http.StatusOK, scrape(newMetricsRouter(s), ""))
+}
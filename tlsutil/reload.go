@@ -0,0 +1,144 @@
+// tlsutil/reload.go
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"estiam/config"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Options configures the verification of client certificates.
+type Options struct {
+	// ClientCAFile holds the PEM certificates of the CAs trusted to sign client certificates.
+	ClientCAFile string
+	// ClientAuth is config.ClientAuthNone, config.ClientAuthOptional (verify
+	// certificates that are sent) or config.ClientAuthRequire.
+	ClientAuth string
+}
+
+// CertReloader serves a certificate and key pair, reloading it when the files change.
+type CertReloader struct {
+	certFile, keyFile string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertReloader loads the certificate and key pair from certFile and keyFile.
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	c := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if _, err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// GetCertificate returns the current certificate; it is a tls.Config.GetCertificate callback.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cert, nil
+}
+
+// Reload loads the pair again if either file changed since the last load, and
+// reports whether it did. On error the current certificate is kept, so that a
+// half-written renewal does not take the server down.
+func (c *CertReloader) Reload() (bool, error) {
+	modTime, err := latestModTime(c.certFile, c.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.RLock()
+	unchanged := c.cert != nil && modTime.Equal(c.modTime)
+	c.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert, c.modTime = &cert, modTime
+	return true, nil
+}
+
+// Watch polls the files every interval and reloads the pair when they change,
+// until ctx is done.
+func (c *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := c.Reload()
+			if err != nil {
+				fmt.Println("Error reloading TLS certificate:", err)
+			} else if reloaded {
+				fmt.Println("Reloaded TLS certificate", c.certFile)
+			}
+		}
+	}
+}
+
+// ServerConfig returns the TLS configuration of the server, serving the
+// certificate of reloader and verifying client certificates as configured.
+func ServerConfig(reloader *CertReloader, opts Options) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	switch opts.ClientAuth {
+	case "", config.ClientAuthNone:
+		return cfg, nil
+	case config.ClientAuthOptional:
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	case config.ClientAuthRequire:
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unsupported client auth %q", opts.ClientAuth)
+	}
+
+	if opts.ClientCAFile == "" {
+		return nil, errors.New("a client CA file is required to verify client certificates")
+	}
+	data, err := os.ReadFile(opts.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ClientCAs = x509.NewCertPool()
+	if !cfg.ClientCAs.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%s: no PEM certificates found", opts.ClientCAFile)
+	}
+	return cfg, nil
+}
+
+// latestModTime returns the latest modification time of files.
+func latestModTime(files ...string) (time.Time, error) {
+	var latest time.Time
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
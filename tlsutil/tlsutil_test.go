@@ -0,0 +1,148 @@
+// tlsutil/tlsutil_test.go
+package tlsutil_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"estiam/config"
+	"estiam/tlsutil"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// issue creates a certificate for name signed by parent, or self-signed when parent is nil.
+func issue(t *testing.T, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert, key
+}
+
+// writePair writes cert and key as PEM files and returns their paths.
+func writePair(t *testing.T, dir, name string, cert *x509.Certificate, key *ecdsa.PrivateKey) (string, string) {
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+	return certFile, keyFile
+}
+
+func TestCertReloader(t *testing.T) {
+	// 1. Load a certificate.
+	dir := t.TempDir()
+	first, firstKey := issue(t, "first", false, nil, nil)
+	certFile, keyFile := writePair(t, dir, "server", first, firstKey)
+
+	reloader, err := tlsutil.NewCertReloader(certFile, keyFile)
+	assert.NoError(t, err)
+	cert, _ := reloader.GetCertificate(nil)
+	assert.Equal(t, first.Raw, cert.Certificate[0])
+
+	// 2. Unchanged files are not reloaded.
+	reloaded, err := reloader.Reload()
+	assert.NoError(t, err)
+	assert.False(t, reloaded)
+
+	// 3. A renewed certificate is picked up.
+	second, secondKey := issue(t, "second", false, nil, nil)
+	writePair(t, dir, "server", second, secondKey)
+	future := time.Now().Add(time.Minute)
+	assert.NoError(t, os.Chtimes(certFile, future, future))
+
+	reloaded, err = reloader.Reload()
+	assert.NoError(t, err)
+	assert.True(t, reloaded)
+	cert, _ = reloader.GetCertificate(nil)
+	assert.Equal(t, second.Raw, cert.Certificate[0])
+
+	// 4. A broken file keeps the current certificate.
+	assert.NoError(t, os.WriteFile(keyFile, []byte("garbage"), 0600))
+	assert.NoError(t, os.Chtimes(keyFile, future.Add(time.Minute), future.Add(time.Minute)))
+	_, err = reloader.Reload()
+	assert.Error(t, err)
+	cert, _ = reloader.GetCertificate(nil)
+	assert.Equal(t, second.Raw, cert.Certificate[0])
+}
+
+func TestServerConfigRequiresClientCert(t *testing.T) {
+	// 1. Create a CA, a server certificate and a client certificate.
+	dir := t.TempDir()
+	ca, caKey := issue(t, "Test CA", true, nil, nil)
+	caFile, _ := writePair(t, dir, "ca", ca, caKey)
+	server, serverKey := issue(t, "localhost", false, ca, caKey)
+	certFile, keyFile := writePair(t, dir, "server", server, serverKey)
+	client, clientKey := issue(t, "alice", false, ca, caKey)
+
+	// 2. Serve with client certificates required, echoing the client name.
+	reloader, err := tlsutil.NewCertReloader(certFile, keyFile)
+	assert.NoError(t, err)
+	cfg, err := tlsutil.ServerConfig(reloader, tlsutil.Options{ClientCAFile: caFile, ClientAuth: config.ClientAuthRequire})
+	assert.NoError(t, err)
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.CommonName))
+	}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	get := func(certs ...tls.Certificate) (string, error) {
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost", Certificates: certs}}}
+		resp, err := c.Get(srv.URL)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	// 3. Clients with a certificate are served; others are refused.
+	body, err := get(tls.Certificate{Certificate: [][]byte{client.Raw}, PrivateKey: clientKey})
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", body)
+
+	_, err = get()
+	assert.Error(t, err)
+
+	// 4. Client verification needs a CA.
+	_, err = tlsutil.ServerConfig(reloader, tlsutil.Options{ClientAuth: config.ClientAuthOptional})
+	assert.Error(t, err)
+}